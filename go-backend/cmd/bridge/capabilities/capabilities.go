@@ -0,0 +1,191 @@
+package capabilities
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-backend/internal/bridge"
+	"go-backend/internal/logger"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/godbus/dbus/v5"
+)
+
+// probeTimeout bounds each probe, so a hung daemon can't stall the report.
+const probeTimeout = 5 * time.Second
+
+var (
+	mu     sync.Mutex
+	report *bridge.CapabilityReport
+)
+
+// Get returns the last report, probing first if there is none yet or refresh is set.
+func Get(ctx context.Context, refresh bool) bridge.CapabilityReport {
+	mu.Lock()
+	defer mu.Unlock()
+	if report == nil || refresh {
+		r := Probe(context.WithoutCancel(ctx)) // the report is shared, so one caller going away must not spoil it
+		report = &r
+	}
+	return *report
+}
+
+// Probe checks every backend concurrently.
+func Probe(ctx context.Context) bridge.CapabilityReport {
+	privileged := os.Geteuid() == 0
+	probes := map[string]func(context.Context, bool) bridge.Capability{
+		bridge.CapSmartctl:       probeSmartctl,
+		bridge.CapNVMe:           probeNVMe,
+		bridge.CapSensors:        probeSensors,
+		bridge.CapDocker:         probeDocker,
+		bridge.CapSystemd:        probeSystemd,
+		bridge.CapNetworkManager: probeNetworkManager,
+		bridge.CapPackageKit:     probePackageKit,
+		bridge.CapWireGuard:      probeWireGuard,
+	}
+
+	var (
+		wg      sync.WaitGroup
+		resMu   sync.Mutex
+		results = make(map[string]bridge.Capability, len(probes))
+	)
+	for name, probe := range probes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pctx, cancel := context.WithTimeout(ctx, probeTimeout)
+			defer cancel()
+			c := probe(pctx, privileged)
+			resMu.Lock()
+			results[name] = c
+			resMu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	var missing []string
+	for name, c := range results {
+		if !c.Usable() {
+			missing = append(missing, name)
+		}
+	}
+	logger.Infof("🔎 Probed %d capabilities, unusable: %v", len(results), missing)
+	return bridge.CapabilityReport{ProbedAt: time.Now(), Privileged: privileged, Capabilities: results}
+}
+
+// probeTool finds a command-line tool and reads its version from the first line of versionArgs' output.
+func probeTool(ctx context.Context, name string, versionArgs ...string) bridge.Capability {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return bridge.Capability{Error: fmt.Sprintf("%s is not installed", name)}
+	}
+	c := bridge.Capability{Present: true, Access: bridge.AccessFull}
+	out, err := exec.CommandContext(ctx, path, versionArgs...).CombinedOutput()
+	if err != nil {
+		c.Error = fmt.Sprintf("%s %s failed: %v", name, strings.Join(versionArgs, " "), err)
+		return c
+	}
+	c.Version, _, _ = strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return c
+}
+
+// rootOnly marks a tool that only works as root as unusable in an unprivileged bridge.
+func rootOnly(c bridge.Capability, privileged bool) bridge.Capability {
+	if c.Present && !privileged {
+		c.Access = bridge.AccessNone
+		c.Error = "requires a privileged session"
+	}
+	return c
+}
+
+func probeSmartctl(ctx context.Context, privileged bool) bridge.Capability {
+	return rootOnly(probeTool(ctx, "smartctl", "--version"), privileged)
+}
+
+func probeNVMe(ctx context.Context, privileged bool) bridge.Capability {
+	return rootOnly(probeTool(ctx, "nvme", "version"), privileged)
+}
+
+func probeSensors(ctx context.Context, privileged bool) bridge.Capability {
+	return probeTool(ctx, "sensors", "-v")
+}
+
+func probeWireGuard(ctx context.Context, privileged bool) bridge.Capability {
+	// wg show needs CAP_NET_ADMIN
+	return rootOnly(probeTool(ctx, "wg", "--version"), privileged)
+}
+
+func probeDocker(ctx context.Context, privileged bool) bridge.Capability {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return bridge.Capability{Error: fmt.Sprintf("docker client error: %v", err)}
+	}
+	defer cli.Close()
+
+	version, err := cli.ServerVersion(ctx)
+	switch {
+	case err == nil:
+		return bridge.Capability{Present: true, Version: version.Version, Access: bridge.AccessFull}
+	case client.IsErrConnectionFailed(err):
+		return bridge.Capability{Error: fmt.Sprintf("docker daemon is not reachable: %v", err)}
+	case errors.Is(err, os.ErrPermission) || strings.Contains(err.Error(), "permission denied"):
+		return bridge.Capability{Present: true, Access: bridge.AccessNone, Error: "no access to the docker socket"}
+	default:
+		return bridge.Capability{Present: true, Error: err.Error()}
+	}
+}
+
+// probeDBusService checks that a D-Bus service answers by reading its version property.
+func probeDBusService(ctx context.Context, service string, path dbus.ObjectPath, versionProps ...string) bridge.Capability {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return bridge.Capability{Error: fmt.Sprintf("failed to connect to system bus: %v", err)}
+	}
+	defer conn.Close()
+
+	obj := conn.Object(service, path)
+	var parts []string
+	for _, prop := range versionProps {
+		var v dbus.Variant
+		iface := prop[:strings.LastIndexByte(prop, '.')]
+		name := prop[strings.LastIndexByte(prop, '.')+1:]
+		if err := obj.CallWithContext(ctx, "org.freedesktop.DBus.Properties.Get", 0, iface, name).Store(&v); err != nil {
+			var dbusErr dbus.Error
+			if errors.As(err, &dbusErr) && (dbusErr.Name == "org.freedesktop.DBus.Error.ServiceUnknown" || dbusErr.Name == "org.freedesktop.DBus.Error.NameHasNoOwner") {
+				return bridge.Capability{Error: fmt.Sprintf("%s is not running", service)}
+			}
+			return bridge.Capability{Present: true, Error: err.Error()}
+		}
+		parts = append(parts, fmt.Sprint(v.Value()))
+	}
+	return bridge.Capability{Present: true, Version: strings.Join(parts, "."), Access: bridge.AccessFull}
+}
+
+// Changes through systemd, NetworkManager and PackageKit are authorized by polkit, which
+// usually only allows them without a prompt to root.
+func polkitAccess(c bridge.Capability, privileged bool) bridge.Capability {
+	if c.Present && c.Access == bridge.AccessFull && !privileged {
+		c.Access = bridge.AccessLimited
+	}
+	return c
+}
+
+func probeSystemd(ctx context.Context, privileged bool) bridge.Capability {
+	return polkitAccess(probeDBusService(ctx, "org.freedesktop.systemd1", "/org/freedesktop/systemd1",
+		"org.freedesktop.systemd1.Manager.Version"), privileged)
+}
+
+func probeNetworkManager(ctx context.Context, privileged bool) bridge.Capability {
+	return polkitAccess(probeDBusService(ctx, "org.freedesktop.NetworkManager", "/org/freedesktop/NetworkManager",
+		"org.freedesktop.NetworkManager.Version"), privileged)
+}
+
+func probePackageKit(ctx context.Context, privileged bool) bridge.Capability {
+	return polkitAccess(probeDBusService(ctx, "org.freedesktop.PackageKit", "/org/freedesktop/PackageKit",
+		"org.freedesktop.PackageKit.VersionMajor", "org.freedesktop.PackageKit.VersionMinor", "org.freedesktop.PackageKit.VersionMicro"), privileged)
+}
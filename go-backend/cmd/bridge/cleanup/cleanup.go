@@ -93,7 +93,9 @@ func killParentTree(pid int) {
 	}
 }
 
-func CheckMainProcessHealth(sess *session.Session) bool {
+// CheckMainProcessHealth asks the server, through the main socket, whether the session is still
+// valid. The socket must be served by serverUID, and the bridge proves itself with its secret.
+func CheckMainProcessHealth(sess *session.Session, serverUID int) bool {
 	sock := bridge.MainSocketPath(sess)
 	conn, err := net.DialTimeout("unix", sock, 2*time.Second)
 	if err != nil {
@@ -102,6 +104,20 @@ func CheckMainProcessHealth(sess *session.Session) bool {
 	}
 	defer conn.Close()
 
+	cred, err := bridge.PeerCred(conn)
+	if err != nil {
+		logger.Warnf("⚠️ Failed to read main socket peer: %v", err)
+		return false
+	}
+	if int(cred.Uid) != serverUID {
+		logger.Warnf("⚠️ Main socket is served by uid %d, not the server (uid %d)", cred.Uid, serverUID)
+		return false
+	}
+	if err := json.NewEncoder(conn).Encode(bridge.Handshake{Secret: sess.BridgeSecret}); err != nil {
+		logger.Warnf("⚠️ Failed to send handshake: %v", err)
+		return false
+	}
+
 	req := BridgeHealthRequest{
 		Type:    "validate",
 		Session: sess.SessionID,
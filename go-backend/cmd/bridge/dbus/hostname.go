@@ -1,12 +1,13 @@
 package dbus
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/godbus/dbus/v5"
 )
 
-func GetHostname() (string, error) {
+func GetHostname(ctx context.Context) (string, error) {
 	var result string
 	err := RetryOnceIfClosed(nil, func() error {
 		conn, err := dbus.SystemBus()
@@ -17,7 +18,7 @@ func GetHostname() (string, error) {
 
 		obj := conn.Object("org.freedesktop.hostname1", "/org/freedesktop/hostname1")
 		var variant dbus.Variant
-		err = obj.Call("org.freedesktop.DBus.Properties.Get", 0,
+		err = obj.CallWithContext(ctx, "org.freedesktop.DBus.Properties.Get", 0,
 			"org.freedesktop.hostname1", "Hostname").Store(&variant)
 		if err != nil {
 			return err
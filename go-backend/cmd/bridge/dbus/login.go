@@ -31,14 +31,14 @@ func (m *Login1Manager) Close() {
 }
 
 // CallLogin1Action is a helper function to call a login1 action, retried if D-Bus is closed.
-func CallLogin1Action(action string) error {
+func CallLogin1Action(ctx context.Context, action string) error {
 	return RetryOnceIfClosed(nil, func() error {
-		manager, err := NewLogin1Manager(context.Background())
+		manager, err := NewLogin1Manager(ctx)
 		if err != nil {
 			return err
 		}
 		defer manager.Close()
-		return manager.call(context.Background(), action)
+		return manager.call(ctx, action)
 	})
 }
 
@@ -1,6 +1,7 @@
 package dbus
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
@@ -64,7 +65,7 @@ func mapDeviceType(devType uint32) string {
 	}
 }
 
-func GetNetworkInfo() ([]NMInterfaceInfo, error) {
+func GetNetworkInfo(ctx context.Context) ([]NMInterfaceInfo, error) {
 	var results []NMInterfaceInfo
 
 	snapshots, _ := net.IOCounters(true)
@@ -83,7 +84,7 @@ func GetNetworkInfo() ([]NMInterfaceInfo, error) {
 		nm := conn.Object("org.freedesktop.NetworkManager", "/org/freedesktop/NetworkManager")
 
 		var devicePaths []dbus.ObjectPath
-		if err := nm.Call("org.freedesktop.NetworkManager.GetDevices", 0).Store(&devicePaths); err != nil {
+		if err := nm.CallWithContext(ctx, "org.freedesktop.NetworkManager.GetDevices", 0).Store(&devicePaths); err != nil {
 			return fmt.Errorf("GetDevices failed: %w", err)
 		}
 
@@ -91,7 +92,7 @@ func GetNetworkInfo() ([]NMInterfaceInfo, error) {
 			dev := conn.Object("org.freedesktop.NetworkManager", devPath)
 
 			props := make(map[string]dbus.Variant)
-			if err := dev.Call("org.freedesktop.DBus.Properties.GetAll", 0, "org.freedesktop.NetworkManager.Device").Store(&props); err != nil {
+			if err := dev.CallWithContext(ctx, "org.freedesktop.DBus.Properties.GetAll", 0, "org.freedesktop.NetworkManager.Device").Store(&props); err != nil {
 				continue
 			}
 
@@ -140,7 +141,7 @@ func GetNetworkInfo() ([]NMInterfaceInfo, error) {
 			if ip4Path, ok := props["Ip4Config"].Value().(dbus.ObjectPath); ok && ip4Path != "/" {
 				ip4Obj := conn.Object("org.freedesktop.NetworkManager", ip4Path)
 				var ip4Props map[string]dbus.Variant
-				if err := ip4Obj.Call("org.freedesktop.DBus.Properties.GetAll", 0, "org.freedesktop.NetworkManager.IP4Config").Store(&ip4Props); err == nil {
+				if err := ip4Obj.CallWithContext(ctx, "org.freedesktop.DBus.Properties.GetAll", 0, "org.freedesktop.NetworkManager.IP4Config").Store(&ip4Props); err == nil {
 					if addresses, ok := ip4Props["Addresses"].Value().([][]uint32); ok {
 						for _, addr := range addresses {
 							ip := fmt.Sprintf("%d.%d.%d.%d/%d",
@@ -162,7 +163,7 @@ func GetNetworkInfo() ([]NMInterfaceInfo, error) {
 			if ip6Path, ok := props["Ip6Config"].Value().(dbus.ObjectPath); ok && ip6Path != "/" {
 				ip6Obj := conn.Object("org.freedesktop.NetworkManager", ip6Path)
 				var ip6Props map[string]dbus.Variant
-				if err := ip6Obj.Call("org.freedesktop.DBus.Properties.GetAll", 0, "org.freedesktop.NetworkManager.IP6Config").Store(&ip6Props); err == nil {
+				if err := ip6Obj.CallWithContext(ctx, "org.freedesktop.DBus.Properties.GetAll", 0, "org.freedesktop.NetworkManager.IP6Config").Store(&ip6Props); err == nil {
 					if addresses, ok := ip6Props["Addresses"].Value().([][]interface{}); ok {
 						for _, tuple := range addresses {
 							addrBytes, _ := tuple[0].([]byte)
@@ -231,7 +232,7 @@ func GetNetworkInfo() ([]NMInterfaceInfo, error) {
 	return results, err
 }
 
-func SetDNS(iface string, dns []string) error {
+func SetDNS(ctx context.Context, iface string, dns []string) error {
 	if strings.TrimSpace(iface) == "" || len(dns) == 0 {
 		return fmt.Errorf("SetDNS requires interface and at least one DNS server")
 	}
@@ -244,14 +245,14 @@ func SetDNS(iface string, dns []string) error {
 
 	nm := conn.Object("org.freedesktop.NetworkManager", "/org/freedesktop/NetworkManager")
 	var devicePaths []dbus.ObjectPath
-	if err := nm.Call("org.freedesktop.NetworkManager.GetDevices", 0).Store(&devicePaths); err != nil {
+	if err := nm.CallWithContext(ctx, "org.freedesktop.NetworkManager.GetDevices", 0).Store(&devicePaths); err != nil {
 		return fmt.Errorf("GetDevices failed: %w", err)
 	}
 
 	for _, devPath := range devicePaths {
 		dev := conn.Object("org.freedesktop.NetworkManager", devPath)
 		var devIface string
-		if err := dev.Call("org.freedesktop.DBus.Properties.Get", 0, "org.freedesktop.NetworkManager.Device", "Interface").Store(&devIface); err != nil {
+		if err := dev.CallWithContext(ctx, "org.freedesktop.DBus.Properties.Get", 0, "org.freedesktop.NetworkManager.Device", "Interface").Store(&devIface); err != nil {
 			continue
 		}
 		if devIface != iface {
@@ -260,19 +261,19 @@ func SetDNS(iface string, dns []string) error {
 
 		// Get connection associated with device
 		var activeConn dbus.ObjectPath
-		if err := dev.Call("org.freedesktop.DBus.Properties.Get", 0, "org.freedesktop.NetworkManager.Device", "ActiveConnection").Store(&activeConn); err != nil {
+		if err := dev.CallWithContext(ctx, "org.freedesktop.DBus.Properties.Get", 0, "org.freedesktop.NetworkManager.Device", "ActiveConnection").Store(&activeConn); err != nil {
 			return fmt.Errorf("failed to get ActiveConnection: %w", err)
 		}
 
 		ac := conn.Object("org.freedesktop.NetworkManager", activeConn)
 		var connPath dbus.ObjectPath
-		if err := ac.Call("org.freedesktop.DBus.Properties.Get", 0, "org.freedesktop.NetworkManager.Connection.Active", "Connection").Store(&connPath); err != nil {
+		if err := ac.CallWithContext(ctx, "org.freedesktop.DBus.Properties.Get", 0, "org.freedesktop.NetworkManager.Connection.Active", "Connection").Store(&connPath); err != nil {
 			return fmt.Errorf("failed to get Connection path: %w", err)
 		}
 
 		settingsConn := conn.Object("org.freedesktop.NetworkManager", connPath)
 		var settings map[string]map[string]dbus.Variant
-		if err := settingsConn.Call("org.freedesktop.NetworkManager.Settings.Connection.GetSettings", 0).Store(&settings); err != nil {
+		if err := settingsConn.CallWithContext(ctx, "org.freedesktop.NetworkManager.Settings.Connection.GetSettings", 0).Store(&settings); err != nil {
 			return fmt.Errorf("failed to get connection settings: %w", err)
 		}
 
@@ -282,17 +283,17 @@ func SetDNS(iface string, dns []string) error {
 		ip4Settings["method"] = dbus.MakeVariant("manual")
 		settings["ipv4"] = ip4Settings
 
-		if err := settingsConn.Call("org.freedesktop.NetworkManager.Settings.Connection.Update", 0, settings).Err; err != nil {
+		if err := settingsConn.CallWithContext(ctx, "org.freedesktop.NetworkManager.Settings.Connection.Update", 0, settings).Err; err != nil {
 			return fmt.Errorf("failed to update connection settings: %w", err)
 		}
 
-		return reloadConnection(conn, connPath)
+		return reloadConnection(ctx, conn, connPath)
 	}
 
 	return fmt.Errorf("interface %s not found", iface)
 }
 
-func SetGateway(iface, gateway string) error {
+func SetGateway(ctx context.Context, iface, gateway string) error {
 	if strings.TrimSpace(iface) == "" || strings.TrimSpace(gateway) == "" {
 		return fmt.Errorf("SetGateway requires interface and gateway address")
 	}
@@ -307,14 +308,14 @@ func SetGateway(iface, gateway string) error {
 
 	// Find the device
 	var devicePaths []dbus.ObjectPath
-	if err := nm.Call("org.freedesktop.NetworkManager.GetDevices", 0).Store(&devicePaths); err != nil {
+	if err := nm.CallWithContext(ctx, "org.freedesktop.NetworkManager.GetDevices", 0).Store(&devicePaths); err != nil {
 		return fmt.Errorf("GetDevices failed: %w", err)
 	}
 
 	for _, devPath := range devicePaths {
 		dev := conn.Object("org.freedesktop.NetworkManager", devPath)
 		var devIface string
-		if err := dev.Call("org.freedesktop.DBus.Properties.Get", 0, "org.freedesktop.NetworkManager.Device", "Interface").Store(&devIface); err != nil {
+		if err := dev.CallWithContext(ctx, "org.freedesktop.DBus.Properties.Get", 0, "org.freedesktop.NetworkManager.Device", "Interface").Store(&devIface); err != nil {
 			continue
 		}
 		if devIface != iface {
@@ -323,19 +324,19 @@ func SetGateway(iface, gateway string) error {
 
 		// Get active connection
 		var activeConn dbus.ObjectPath
-		if err := dev.Call("org.freedesktop.DBus.Properties.Get", 0, "org.freedesktop.NetworkManager.Device", "ActiveConnection").Store(&activeConn); err != nil {
+		if err := dev.CallWithContext(ctx, "org.freedesktop.DBus.Properties.Get", 0, "org.freedesktop.NetworkManager.Device", "ActiveConnection").Store(&activeConn); err != nil {
 			return fmt.Errorf("failed to get ActiveConnection: %w", err)
 		}
 
 		ac := conn.Object("org.freedesktop.NetworkManager", activeConn)
 		var connPath dbus.ObjectPath
-		if err := ac.Call("org.freedesktop.DBus.Properties.Get", 0, "org.freedesktop.NetworkManager.Connection.Active", "Connection").Store(&connPath); err != nil {
+		if err := ac.CallWithContext(ctx, "org.freedesktop.DBus.Properties.Get", 0, "org.freedesktop.NetworkManager.Connection.Active", "Connection").Store(&connPath); err != nil {
 			return fmt.Errorf("failed to get Connection path: %w", err)
 		}
 
 		settingsConn := conn.Object("org.freedesktop.NetworkManager", connPath)
 		var settings map[string]map[string]dbus.Variant
-		if err := settingsConn.Call("org.freedesktop.NetworkManager.Settings.Connection.GetSettings", 0).Store(&settings); err != nil {
+		if err := settingsConn.CallWithContext(ctx, "org.freedesktop.NetworkManager.Settings.Connection.GetSettings", 0).Store(&settings); err != nil {
 			return fmt.Errorf("failed to get connection settings: %w", err)
 		}
 
@@ -345,53 +346,53 @@ func SetGateway(iface, gateway string) error {
 		ip4Settings["method"] = dbus.MakeVariant("manual")
 		settings["ipv4"] = ip4Settings
 
-		if err := settingsConn.Call("org.freedesktop.NetworkManager.Settings.Connection.Update", 0, settings).Err; err != nil {
+		if err := settingsConn.CallWithContext(ctx, "org.freedesktop.NetworkManager.Settings.Connection.Update", 0, settings).Err; err != nil {
 			return fmt.Errorf("failed to update connection settings: %w", err)
 		}
 
-		return reloadConnection(conn, connPath)
+		return reloadConnection(ctx, conn, connPath)
 	}
 
 	return fmt.Errorf("interface %s not found", iface)
 }
 
-func SetIPv4DHCP(iface string) error {
-	cmd := exec.Command("nmcli", "con", "mod", iface, "ipv4.method", "auto")
+func SetIPv4DHCP(ctx context.Context, iface string) error {
+	cmd := exec.CommandContext(ctx, "nmcli", "con", "mod", iface, "ipv4.method", "auto")
 	if err := cmd.Run(); err != nil {
 		return err
 	}
-	cmd = exec.Command("nmcli", "con", "up", iface)
+	cmd = exec.CommandContext(ctx, "nmcli", "con", "up", iface)
 	return cmd.Run()
 }
 
-func SetIPv4Static(iface, addressCIDR string) error {
-	cmd := exec.Command("nmcli", "con", "mod", iface, "ipv4.addresses", addressCIDR, "ipv4.method", "manual")
+func SetIPv4Static(ctx context.Context, iface, addressCIDR string) error {
+	cmd := exec.CommandContext(ctx, "nmcli", "con", "mod", iface, "ipv4.addresses", addressCIDR, "ipv4.method", "manual")
 	if err := cmd.Run(); err != nil {
 		return err
 	}
-	cmd = exec.Command("nmcli", "con", "up", iface)
+	cmd = exec.CommandContext(ctx, "nmcli", "con", "up", iface)
 	return cmd.Run()
 }
 
-func SetIPv6DHCP(iface string) error {
-	cmd := exec.Command("nmcli", "con", "mod", iface, "ipv6.method", "auto")
+func SetIPv6DHCP(ctx context.Context, iface string) error {
+	cmd := exec.CommandContext(ctx, "nmcli", "con", "mod", iface, "ipv6.method", "auto")
 	if err := cmd.Run(); err != nil {
 		return err
 	}
-	cmd = exec.Command("nmcli", "con", "up", iface)
+	cmd = exec.CommandContext(ctx, "nmcli", "con", "up", iface)
 	return cmd.Run()
 }
 
-func SetIPv6Static(iface, addressCIDR string) error {
-	cmd := exec.Command("nmcli", "con", "mod", iface, "ipv6.addresses", addressCIDR, "ipv6.method", "manual")
+func SetIPv6Static(ctx context.Context, iface, addressCIDR string) error {
+	cmd := exec.CommandContext(ctx, "nmcli", "con", "mod", iface, "ipv6.addresses", addressCIDR, "ipv6.method", "manual")
 	if err := cmd.Run(); err != nil {
 		return err
 	}
-	cmd = exec.Command("nmcli", "con", "up", iface)
+	cmd = exec.CommandContext(ctx, "nmcli", "con", "up", iface)
 	return cmd.Run()
 }
 
-func SetMTU(iface, mtu string) error {
+func SetMTU(ctx context.Context, iface, mtu string) error {
 	if strings.TrimSpace(iface) == "" || strings.TrimSpace(mtu) == "" {
 		return fmt.Errorf("SetMTU requires interface and MTU value")
 	}
@@ -406,14 +407,14 @@ func SetMTU(iface, mtu string) error {
 
 	// Find device
 	var devicePaths []dbus.ObjectPath
-	if err := nm.Call("org.freedesktop.NetworkManager.GetDevices", 0).Store(&devicePaths); err != nil {
+	if err := nm.CallWithContext(ctx, "org.freedesktop.NetworkManager.GetDevices", 0).Store(&devicePaths); err != nil {
 		return fmt.Errorf("GetDevices failed: %w", err)
 	}
 
 	for _, devPath := range devicePaths {
 		dev := conn.Object("org.freedesktop.NetworkManager", devPath)
 		var devIface string
-		if err := dev.Call("org.freedesktop.DBus.Properties.Get", 0, "org.freedesktop.NetworkManager.Device", "Interface").Store(&devIface); err != nil {
+		if err := dev.CallWithContext(ctx, "org.freedesktop.DBus.Properties.Get", 0, "org.freedesktop.NetworkManager.Device", "Interface").Store(&devIface); err != nil {
 			continue
 		}
 		if devIface != iface {
@@ -422,19 +423,19 @@ func SetMTU(iface, mtu string) error {
 
 		// Get active connection
 		var activeConn dbus.ObjectPath
-		if err := dev.Call("org.freedesktop.DBus.Properties.Get", 0, "org.freedesktop.NetworkManager.Device", "ActiveConnection").Store(&activeConn); err != nil {
+		if err := dev.CallWithContext(ctx, "org.freedesktop.DBus.Properties.Get", 0, "org.freedesktop.NetworkManager.Device", "ActiveConnection").Store(&activeConn); err != nil {
 			return fmt.Errorf("failed to get ActiveConnection: %w", err)
 		}
 
 		ac := conn.Object("org.freedesktop.NetworkManager", activeConn)
 		var connPath dbus.ObjectPath
-		if err := ac.Call("org.freedesktop.DBus.Properties.Get", 0, "org.freedesktop.NetworkManager.Connection.Active", "Connection").Store(&connPath); err != nil {
+		if err := ac.CallWithContext(ctx, "org.freedesktop.DBus.Properties.Get", 0, "org.freedesktop.NetworkManager.Connection.Active", "Connection").Store(&connPath); err != nil {
 			return fmt.Errorf("failed to get Connection path: %w", err)
 		}
 
 		settingsConn := conn.Object("org.freedesktop.NetworkManager", connPath)
 		var settings map[string]map[string]dbus.Variant
-		if err := settingsConn.Call("org.freedesktop.NetworkManager.Settings.Connection.GetSettings", 0).Store(&settings); err != nil {
+		if err := settingsConn.CallWithContext(ctx, "org.freedesktop.NetworkManager.Settings.Connection.GetSettings", 0).Store(&settings); err != nil {
 			return fmt.Errorf("failed to get connection settings: %w", err)
 		}
 
@@ -448,11 +449,11 @@ func SetMTU(iface, mtu string) error {
 		ethernetSettings["mtu"] = dbus.MakeVariant(uint32(mtuValue))
 		settings["802-3-ethernet"] = ethernetSettings
 
-		if err := settingsConn.Call("org.freedesktop.NetworkManager.Settings.Connection.Update", 0, settings).Err; err != nil {
+		if err := settingsConn.CallWithContext(ctx, "org.freedesktop.NetworkManager.Settings.Connection.Update", 0, settings).Err; err != nil {
 			return fmt.Errorf("failed to update MTU: %w", err)
 		}
 
-		return reloadConnection(conn, connPath)
+		return reloadConnection(ctx, conn, connPath)
 	}
 
 	return fmt.Errorf("interface %s not found", iface)
@@ -461,12 +462,12 @@ func SetMTU(iface, mtu string) error {
 // reloadConnection deactivates and reactivates the specified connection
 // to apply changes made to its settings.
 // It returns an error if the operation fails.
-func reloadConnection(conn *dbus.Conn, connPath dbus.ObjectPath) error {
+func reloadConnection(ctx context.Context, conn *dbus.Conn, connPath dbus.ObjectPath) error {
 	// Deactivate and reactivate the connection
 	nm := conn.Object("org.freedesktop.NetworkManager", "/org/freedesktop/NetworkManager")
 
 	var activeConns []dbus.ObjectPath
-	if err := nm.Call("org.freedesktop.NetworkManager.GetActiveConnections", 0).Store(&activeConns); err != nil {
+	if err := nm.CallWithContext(ctx, "org.freedesktop.NetworkManager.GetActiveConnections", 0).Store(&activeConns); err != nil {
 		return fmt.Errorf("failed to get active connections: %w", err)
 	}
 
@@ -474,7 +475,7 @@ func reloadConnection(conn *dbus.Conn, connPath dbus.ObjectPath) error {
 	for _, ac := range activeConns {
 		acObj := conn.Object("org.freedesktop.NetworkManager", ac)
 		var c dbus.ObjectPath
-		if err := acObj.Call("org.freedesktop.DBus.Properties.Get", 0, "org.freedesktop.NetworkManager.Connection.Active", "Connection").Store(&c); err == nil {
+		if err := acObj.CallWithContext(ctx, "org.freedesktop.DBus.Properties.Get", 0, "org.freedesktop.NetworkManager.Connection.Active", "Connection").Store(&c); err == nil {
 			if c == connPath {
 				connToDeactivate = ac
 				break
@@ -483,7 +484,7 @@ func reloadConnection(conn *dbus.Conn, connPath dbus.ObjectPath) error {
 	}
 
 	if connToDeactivate != "" {
-		_ = nm.Call("org.freedesktop.NetworkManager.DeactivateConnection", 0, connToDeactivate)
+		_ = nm.CallWithContext(ctx, "org.freedesktop.NetworkManager.DeactivateConnection", 0, connToDeactivate)
 	}
 
 	return nil
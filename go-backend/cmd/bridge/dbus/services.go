@@ -1,6 +1,7 @@
 package dbus
 
 import (
+	"context"
 	"fmt"
 	"go-backend/internal/logger"
 	"strings"
@@ -17,7 +18,7 @@ type ServiceStatus struct {
 }
 
 // --- List all services (robust) ---
-func ListServices() ([]ServiceStatus, error) {
+func ListServices(ctx context.Context) ([]ServiceStatus, error) {
 	var services []ServiceStatus
 	err := RetryOnceIfClosed(nil, func() error {
 		conn, err := dbus.SystemBus()
@@ -28,7 +29,7 @@ func ListServices() ([]ServiceStatus, error) {
 
 		systemd := conn.Object("org.freedesktop.systemd1", "/org/freedesktop/systemd1")
 		var units [][]interface{}
-		if err := systemd.Call("org.freedesktop.systemd1.Manager.ListUnits", 0).Store(&units); err != nil {
+		if err := systemd.CallWithContext(ctx, "org.freedesktop.systemd1.Manager.ListUnits", 0).Store(&units); err != nil {
 			return err
 		}
 
@@ -52,7 +53,7 @@ func ListServices() ([]ServiceStatus, error) {
 }
 
 // --- Get detailed info about a single service (robust) ---
-func GetServiceInfo(serviceName string) (map[string]interface{}, error) {
+func GetServiceInfo(ctx context.Context, serviceName string) (map[string]interface{}, error) {
 	serviceName = strings.TrimSpace(serviceName)
 	if serviceName == "" {
 		err := fmt.Errorf("missing service name")
@@ -70,7 +71,7 @@ func GetServiceInfo(serviceName string) (map[string]interface{}, error) {
 
 		systemd := conn.Object("org.freedesktop.systemd1", "/org/freedesktop/systemd1")
 		var unitPath dbus.ObjectPath
-		if err := systemd.Call("org.freedesktop.systemd1.Manager.GetUnit", 0, serviceName).Store(&unitPath); err != nil {
+		if err := systemd.CallWithContext(ctx, "org.freedesktop.systemd1.Manager.GetUnit", 0, serviceName).Store(&unitPath); err != nil {
 			return err
 		}
 		unit := conn.Object("org.freedesktop.systemd1", unitPath)
@@ -98,7 +99,7 @@ func GetServiceInfo(serviceName string) (map[string]interface{}, error) {
 }
 
 // Start a service
-func StartService(name string) error {
+func StartService(ctx context.Context, name string) error {
 	return RetryOnceIfClosed(nil, func() error {
 		conn, err := dbus.SystemBus()
 		if err != nil {
@@ -107,13 +108,13 @@ func StartService(name string) error {
 		defer conn.Close()
 		systemd := conn.Object("org.freedesktop.systemd1", "/org/freedesktop/systemd1")
 		// "replace" is the mode systemctl uses by default
-		call := systemd.Call("org.freedesktop.systemd1.Manager.StartUnit", 0, name, "replace")
+		call := systemd.CallWithContext(ctx, "org.freedesktop.systemd1.Manager.StartUnit", 0, name, "replace")
 		return call.Err
 	})
 }
 
 // Stop a service
-func StopService(name string) error {
+func StopService(ctx context.Context, name string) error {
 	return RetryOnceIfClosed(nil, func() error {
 		conn, err := dbus.SystemBus()
 		if err != nil {
@@ -121,13 +122,13 @@ func StopService(name string) error {
 		}
 		defer conn.Close()
 		systemd := conn.Object("org.freedesktop.systemd1", "/org/freedesktop/systemd1")
-		call := systemd.Call("org.freedesktop.systemd1.Manager.StopUnit", 0, name, "replace")
+		call := systemd.CallWithContext(ctx, "org.freedesktop.systemd1.Manager.StopUnit", 0, name, "replace")
 		return call.Err
 	})
 }
 
 // Restart a service
-func RestartService(name string) error {
+func RestartService(ctx context.Context, name string) error {
 	return RetryOnceIfClosed(nil, func() error {
 		conn, err := dbus.SystemBus()
 		if err != nil {
@@ -135,13 +136,13 @@ func RestartService(name string) error {
 		}
 		defer conn.Close()
 		systemd := conn.Object("org.freedesktop.systemd1", "/org/freedesktop/systemd1")
-		call := systemd.Call("org.freedesktop.systemd1.Manager.RestartUnit", 0, name, "replace")
+		call := systemd.CallWithContext(ctx, "org.freedesktop.systemd1.Manager.RestartUnit", 0, name, "replace")
 		return call.Err
 	})
 }
 
 // Reload a service (if supported)
-func ReloadService(name string) error {
+func ReloadService(ctx context.Context, name string) error {
 	return RetryOnceIfClosed(nil, func() error {
 		conn, err := dbus.SystemBus()
 		if err != nil {
@@ -149,13 +150,13 @@ func ReloadService(name string) error {
 		}
 		defer conn.Close()
 		systemd := conn.Object("org.freedesktop.systemd1", "/org/freedesktop/systemd1")
-		call := systemd.Call("org.freedesktop.systemd1.Manager.ReloadUnit", 0, name, "replace")
+		call := systemd.CallWithContext(ctx, "org.freedesktop.systemd1.Manager.ReloadUnit", 0, name, "replace")
 		return call.Err
 	})
 }
 
 // Enable a service (for boot)
-func EnableService(name string) error {
+func EnableService(ctx context.Context, name string) error {
 	return RetryOnceIfClosed(nil, func() error {
 		conn, err := dbus.SystemBus()
 		if err != nil {
@@ -164,13 +165,13 @@ func EnableService(name string) error {
 		defer conn.Close()
 		systemd := conn.Object("org.freedesktop.systemd1", "/org/freedesktop/systemd1")
 		// changes := ...  // <-- REMOVE THIS
-		call := systemd.Call("org.freedesktop.systemd1.Manager.EnableUnitFiles", 0, []string{name}, false, true)
+		call := systemd.CallWithContext(ctx, "org.freedesktop.systemd1.Manager.EnableUnitFiles", 0, []string{name}, false, true)
 		return call.Err
 	})
 }
 
 // Disable a service (prevent start at boot)
-func DisableService(name string) error {
+func DisableService(ctx context.Context, name string) error {
 	return RetryOnceIfClosed(nil, func() error {
 		conn, err := dbus.SystemBus()
 		if err != nil {
@@ -179,13 +180,13 @@ func DisableService(name string) error {
 		defer conn.Close()
 		systemd := conn.Object("org.freedesktop.systemd1", "/org/freedesktop/systemd1")
 		// changes := ...  // <-- REMOVE THIS
-		call := systemd.Call("org.freedesktop.systemd1.Manager.DisableUnitFiles", 0, []string{name}, false)
+		call := systemd.CallWithContext(ctx, "org.freedesktop.systemd1.Manager.DisableUnitFiles", 0, []string{name}, false)
 		return call.Err
 	})
 }
 
 // Mask a service (make it unstartable even manually)
-func MaskService(name string) error {
+func MaskService(ctx context.Context, name string) error {
 	return RetryOnceIfClosed(nil, func() error {
 		conn, err := dbus.SystemBus()
 		if err != nil {
@@ -194,13 +195,13 @@ func MaskService(name string) error {
 		defer conn.Close()
 		systemd := conn.Object("org.freedesktop.systemd1", "/org/freedesktop/systemd1")
 		// changes := ...  // <-- REMOVE THIS
-		call := systemd.Call("org.freedesktop.systemd1.Manager.MaskUnitFiles", 0, []string{name}, false, true)
+		call := systemd.CallWithContext(ctx, "org.freedesktop.systemd1.Manager.MaskUnitFiles", 0, []string{name}, false, true)
 		return call.Err
 	})
 }
 
 // Unmask a service
-func UnmaskService(name string) error {
+func UnmaskService(ctx context.Context, name string) error {
 	return RetryOnceIfClosed(nil, func() error {
 		conn, err := dbus.SystemBus()
 		if err != nil {
@@ -209,7 +210,7 @@ func UnmaskService(name string) error {
 		defer conn.Close()
 		systemd := conn.Object("org.freedesktop.systemd1", "/org/freedesktop/systemd1")
 		// changes := ...  // <-- REMOVE THIS
-		call := systemd.Call("org.freedesktop.systemd1.Manager.UnmaskUnitFiles", 0, []string{name}, false)
+		call := systemd.CallWithContext(ctx, "org.freedesktop.systemd1.Manager.UnmaskUnitFiles", 0, []string{name}, false)
 		return call.Err
 	})
 }
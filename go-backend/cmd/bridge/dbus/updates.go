@@ -3,6 +3,7 @@ package dbus
 import (
 	"context"
 	"fmt"
+	"go-backend/internal/logger"
 	"regexp"
 	"strings"
 	"time"
@@ -23,6 +24,32 @@ type UpdateDetail struct {
 
 // --- Helpers ---
 
+// pkStatusNames are PackageKit's PK_STATUS_ENUM values, as pk_status_enum_to_string names them.
+var pkStatusNames = []string{
+	"unknown", "wait", "setup", "running", "query", "info", "remove", "refresh-cache",
+	"download", "install", "update", "cleanup", "obsolete", "dep-resolve", "sig-check",
+	"test-commit", "commit", "request", "finished", "cancel", "download-repository",
+	"download-packagelist", "download-filelist", "download-changelog", "download-group",
+	"download-updateinfo", "repackaging", "loading-cache", "scan-applications",
+	"generate-package-list", "waiting-for-lock", "waiting-for-auth", "scan-process-list",
+	"check-executable-files", "check-libraries", "copy-files", "run-hook",
+}
+
+func pkStatusName(status uint32) string {
+	if int(status) < len(pkStatusNames) {
+		return pkStatusNames[status]
+	}
+	return "unknown"
+}
+
+// pkPercent converts a transaction Percentage, where 101 means unknown.
+func pkPercent(p uint32) int {
+	if p > 100 {
+		return -1
+	}
+	return int(p)
+}
+
 func extractCVEs(text string) []string {
 	re := regexp.MustCompile(`CVE-\d{4}-\d+`)
 	return re.FindAllString(text, -1)
@@ -69,10 +96,10 @@ func toStringSlice(iface any) []string {
 
 // --- D-Bus Public Wrappers with Retry ---
 
-func GetUpdatesWithDetails() ([]UpdateDetail, error) {
+func GetUpdatesWithDetails(ctx context.Context) ([]UpdateDetail, error) {
 	var result []UpdateDetail
 	err := RetryOnceIfClosed(nil, func() error {
-		details, err := getUpdatesWithDetails()
+		details, err := getUpdatesWithDetails(ctx)
 		if err != nil {
 			return err
 		}
@@ -85,15 +112,17 @@ func GetUpdatesWithDetails() ([]UpdateDetail, error) {
 	return result, err
 }
 
-func InstallPackage(packageID string) error {
+// InstallPackage installs a package through PackageKit. onProgress receives the transaction's
+// Percentage (-1 when PackageKit can't tell) and Status whenever either changes.
+func InstallPackage(ctx context.Context, packageID string, onProgress func(percent int, status string)) error {
 	return RetryOnceIfClosed(nil, func() error {
-		return installPackage(packageID)
+		return installPackage(ctx, packageID, onProgress)
 	})
 }
 
 // --- Private Implementation ---
 
-func getUpdatesWithDetails() ([]UpdateDetail, error) {
+func getUpdatesWithDetails(ctx context.Context) ([]UpdateDetail, error) {
 	conn, err := dbus.SystemBus()
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to system bus: %w", err)
@@ -109,7 +138,7 @@ func getUpdatesWithDetails() ([]UpdateDetail, error) {
 	// 1. First transaction: GetUpdates
 	obj := conn.Object(pkBusName, dbus.ObjectPath(pkObjPath))
 	var updatesTransPath dbus.ObjectPath
-	if err := obj.Call("org.freedesktop.PackageKit.CreateTransaction", 0).Store(&updatesTransPath); err != nil {
+	if err := obj.CallWithContext(ctx, "org.freedesktop.PackageKit.CreateTransaction", 0).Store(&updatesTransPath); err != nil {
 		return nil, fmt.Errorf("CreateTransaction failed: %w", err)
 	}
 	updatesTrans := conn.Object(pkBusName, updatesTransPath)
@@ -120,14 +149,14 @@ func getUpdatesWithDetails() ([]UpdateDetail, error) {
 		dbus.WithMatchObjectPath(updatesTransPath),
 	)
 
-	getUpdatesCall := updatesTrans.Call(transactionIfc+".GetUpdates", 0, uint64(0))
+	getUpdatesCall := updatesTrans.CallWithContext(ctx, transactionIfc+".GetUpdates", 0, uint64(0))
 	if getUpdatesCall.Err != nil {
 		return nil, fmt.Errorf("GetUpdates failed: %w", getUpdatesCall.Err)
 	}
 
 	var pkgIDs []string
 	var summaries []string
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	collectCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
 	defer cancel()
 
 collectPackages:
@@ -147,7 +176,10 @@ collectPackages:
 			} else if sig.Name == transactionIfc+".Finished" {
 				break collectPackages
 			}
-		case <-ctx.Done():
+		case <-collectCtx.Done():
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("waiting for PackageKit updates: %w", ctx.Err())
+			}
 			break collectPackages
 		}
 	}
@@ -158,7 +190,7 @@ collectPackages:
 
 	// 2. New transaction: GetUpdateDetail
 	var detailsTransPath dbus.ObjectPath
-	if err := obj.Call("org.freedesktop.PackageKit.CreateTransaction", 0).Store(&detailsTransPath); err != nil {
+	if err := obj.CallWithContext(ctx, "org.freedesktop.PackageKit.CreateTransaction", 0).Store(&detailsTransPath); err != nil {
 		return nil, fmt.Errorf("CreateTransaction (for details) failed: %w", err)
 	}
 	detailsTrans := conn.Object(pkBusName, detailsTransPath)
@@ -169,13 +201,13 @@ collectPackages:
 		dbus.WithMatchObjectPath(detailsTransPath),
 	)
 
-	detailCall := detailsTrans.Call(transactionIfc+".GetUpdateDetail", 0, pkgIDs)
+	detailCall := detailsTrans.CallWithContext(ctx, transactionIfc+".GetUpdateDetail", 0, pkgIDs)
 	if detailCall.Err != nil {
 		return nil, fmt.Errorf("GetUpdateDetail failed: %w", detailCall.Err)
 	}
 
 	var details []UpdateDetail
-	ctx2, cancel2 := context.WithTimeout(context.Background(), 15*time.Second)
+	detailsCtx, cancel2 := context.WithTimeout(ctx, 15*time.Second)
 	defer cancel2()
 
 	summaryByPkg := map[string]string{}
@@ -239,7 +271,7 @@ collectDetails:
 			} else if sig.Name == transactionIfc+".Finished" {
 				break collectDetails
 			}
-		case <-ctx2.Done():
+		case <-detailsCtx.Done():
 			break collectDetails
 		}
 	}
@@ -247,7 +279,7 @@ collectDetails:
 	return details, nil
 }
 
-func installPackage(packageID string) error {
+func installPackage(ctx context.Context, packageID string, onProgress func(percent int, status string)) error {
 	conn, err := dbus.SystemBus()
 	if err != nil {
 		return fmt.Errorf("failed to connect to system bus: %w", err)
@@ -263,26 +295,25 @@ func installPackage(packageID string) error {
 	// 1. Create Transaction
 	obj := conn.Object(pkBusName, dbus.ObjectPath(pkObjPath))
 	var transPath dbus.ObjectPath
-	if err := obj.Call("org.freedesktop.PackageKit.CreateTransaction", 0).Store(&transPath); err != nil {
+	if err := obj.CallWithContext(ctx, "org.freedesktop.PackageKit.CreateTransaction", 0).Store(&transPath); err != nil {
 		return fmt.Errorf("CreateTransaction failed: %w", err)
 	}
 	trans := conn.Object(pkBusName, transPath)
 
-	// Listen for signals
+	// Listen for signals, including property changes carrying Percentage and Status
 	sigCh := make(chan *dbus.Signal, 20)
 	conn.Signal(sigCh)
 	conn.AddMatchSignal(dbus.WithMatchObjectPath(transPath))
 
+	percent, status := -1, pkStatusName(0)
+
 	// 2. Call InstallPackages
-	call := trans.Call(transactionIfc+".InstallPackages", 0, uint64(0), []string{packageID})
+	call := trans.CallWithContext(ctx, transactionIfc+".InstallPackages", 0, uint64(0), []string{packageID})
 	if call.Err != nil {
 		return fmt.Errorf("InstallPackages failed: %w", call.Err)
 	}
 
-	// 3. Wait for Finished/ErrorCode signal
-	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
-	defer cancel()
-
+	// 3. Wait for Finished/ErrorCode signal, until the request's deadline
 	for {
 		select {
 		case sig := <-sigCh:
@@ -290,6 +321,30 @@ func installPackage(packageID string) error {
 				return fmt.Errorf("nil signal from D-Bus")
 			}
 			switch sig.Name {
+			case "org.freedesktop.DBus.Properties.PropertiesChanged":
+				if len(sig.Body) < 2 {
+					continue
+				}
+				if ifc, _ := sig.Body[0].(string); ifc != transactionIfc {
+					continue
+				}
+				changed, _ := sig.Body[1].(map[string]dbus.Variant)
+				updated := false
+				if v, ok := changed["Percentage"]; ok {
+					if p, ok := v.Value().(uint32); ok {
+						percent = pkPercent(p)
+						updated = true
+					}
+				}
+				if v, ok := changed["Status"]; ok {
+					if st, ok := v.Value().(uint32); ok {
+						status = pkStatusName(st)
+						updated = true
+					}
+				}
+				if updated && onProgress != nil {
+					onProgress(percent, status)
+				}
 			case transactionIfc + ".ErrorCode":
 				code, _ := sig.Body[0].(uint32)
 				msg, _ := sig.Body[1].(string)
@@ -299,7 +354,11 @@ func installPackage(packageID string) error {
 				return nil
 			}
 		case <-ctx.Done():
-			return fmt.Errorf("timeout waiting for PackageKit to finish install")
+			// Don't leave the transaction running once nobody waits for it
+			if err := trans.Call(transactionIfc+".Cancel", 0).Err; err != nil {
+				logger.Warnf("Failed to cancel PackageKit transaction %s: %v", transPath, err)
+			}
+			return fmt.Errorf("waiting for PackageKit to finish install: %w", ctx.Err())
 		}
 	}
 }
@@ -31,14 +31,14 @@ func getClient() (*client.Client, error) {
 }
 
 // List all containers with metrics
-func ListContainers() (any, error) {
+func ListContainers(ctx context.Context) (any, error) {
 	cli, err := getClient()
 	if err != nil {
 		return nil, fmt.Errorf("docker client error: %w", err)
 	}
 	defer cli.Close()
 
-	containers, err := cli.ContainerList(context.Background(), container.ListOptions{All: true})
+	containers, err := cli.ContainerList(ctx, container.ListOptions{All: true})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list containers: %w", err)
 	}
@@ -47,7 +47,7 @@ func ListContainers() (any, error) {
 
 	for _, ctr := range containers {
 		metrics := &Metrics{}
-		statsResp, err := cli.ContainerStatsOneShot(context.Background(), ctr.ID)
+		statsResp, err := cli.ContainerStatsOneShot(ctx, ctr.ID)
 		if err == nil {
 			var stats struct {
 				CPUStats struct {
@@ -108,14 +108,14 @@ func ListContainers() (any, error) {
 }
 
 // Start a container by ID
-func StartContainer(id string) (any, error) {
+func StartContainer(ctx context.Context, id string) (any, error) {
 	cli, err := getClient()
 	if err != nil {
 		return nil, fmt.Errorf("docker client error: %w", err)
 	}
 	defer cli.Close()
 
-	if err := cli.ContainerStart(context.Background(), id, container.StartOptions{}); err != nil {
+	if err := cli.ContainerStart(ctx, id, container.StartOptions{}); err != nil {
 		return nil, fmt.Errorf("failed to start container: %w", err)
 	}
 
@@ -123,14 +123,14 @@ func StartContainer(id string) (any, error) {
 }
 
 // Stop a container by ID
-func StopContainer(id string) (any, error) {
+func StopContainer(ctx context.Context, id string) (any, error) {
 	cli, err := getClient()
 	if err != nil {
 		return nil, fmt.Errorf("docker client error: %w", err)
 	}
 	defer cli.Close()
 
-	if err := cli.ContainerStop(context.Background(), id, container.StopOptions{}); err != nil {
+	if err := cli.ContainerStop(ctx, id, container.StopOptions{}); err != nil {
 		return nil, fmt.Errorf("failed to stop container: %w", err)
 	}
 
@@ -138,14 +138,14 @@ func StopContainer(id string) (any, error) {
 }
 
 // Remove a container by ID
-func RemoveContainer(id string) (any, error) {
+func RemoveContainer(ctx context.Context, id string) (any, error) {
 	cli, err := getClient()
 	if err != nil {
 		return nil, fmt.Errorf("docker client error: %w", err)
 	}
 	defer cli.Close()
 
-	if err := cli.ContainerRemove(context.Background(), id, container.RemoveOptions{Force: true}); err != nil {
+	if err := cli.ContainerRemove(ctx, id, container.RemoveOptions{Force: true}); err != nil {
 		return nil, fmt.Errorf("failed to remove container: %w", err)
 	}
 
@@ -153,14 +153,14 @@ func RemoveContainer(id string) (any, error) {
 }
 
 // Restart a container by ID
-func RestartContainer(id string) (any, error) {
+func RestartContainer(ctx context.Context, id string) (any, error) {
 	cli, err := getClient()
 	if err != nil {
 		return nil, fmt.Errorf("docker client error: %w", err)
 	}
 	defer cli.Close()
 
-	if err := cli.ContainerRestart(context.Background(), id, container.StopOptions{}); err != nil {
+	if err := cli.ContainerRestart(ctx, id, container.StopOptions{}); err != nil {
 		return nil, fmt.Errorf("failed to restart container: %w", err)
 	}
 
@@ -168,14 +168,14 @@ func RestartContainer(id string) (any, error) {
 }
 
 // List all images
-func ListImages() (any, error) {
+func ListImages(ctx context.Context) (any, error) {
 	cli, err := getClient()
 	if err != nil {
 		return nil, fmt.Errorf("docker client error: %w", err)
 	}
 	defer cli.Close()
 
-	images, err := cli.ImageList(context.Background(), image.ListOptions{All: true})
+	images, err := cli.ImageList(ctx, image.ListOptions{All: true})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list images: %w", err)
 	}
@@ -0,0 +1,127 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-backend/internal/bridge"
+	"go-backend/internal/logger"
+	"go-backend/internal/session"
+	"net"
+	"time"
+)
+
+const (
+	// events waiting for the server; more are dropped rather than blocking their source
+	queueSize = 256
+	// reconnect delays of the event stream and of sources whose backend went away
+	retryMin = 5 * time.Second
+	retryMax = 5 * time.Minute
+)
+
+var queue = make(chan bridge.Event, queueSize)
+
+// Publish queues an event for the server. It never blocks: when the server isn't keeping
+// up, the event is dropped.
+func Publish(channel, eventType string, data any) {
+	ev, err := bridge.NewEvent(channel, eventType, data)
+	if err != nil {
+		logger.Warnf("Failed to encode %s event: %v", channel, err)
+		return
+	}
+	select {
+	case queue <- ev:
+	default:
+		logger.Warnf("Event queue full, dropping %s %s event", channel, eventType)
+	}
+}
+
+// Run forwards published events to the server over the main socket until ctx ends,
+// reconnecting whenever the stream breaks.
+func Run(ctx context.Context, sess *session.Session, serverUID int) {
+	retry(ctx, "event stream", func(ctx context.Context) error {
+		conn, err := connect(sess, serverUID)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		logger.Infof("📡 Event stream to server open")
+
+		// The server never writes after accepting; a read returning means it hung up
+		closed := make(chan struct{})
+		go func() {
+			_, _ = conn.Read(make([]byte, 1))
+			close(closed)
+		}()
+
+		enc := json.NewEncoder(conn)
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-closed:
+				return fmt.Errorf("server closed the event stream")
+			case ev := <-queue:
+				if err := enc.Encode(ev); err != nil {
+					return fmt.Errorf("failed to send %s event: %w", ev.Channel, err)
+				}
+			}
+		}
+	})
+}
+
+// connect opens an event stream on the main socket, checking that the server serves it.
+func connect(sess *session.Session, serverUID int) (net.Conn, error) {
+	conn, err := net.DialTimeout("unix", bridge.MainSocketPath(sess), 2*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	cred, err := bridge.PeerCred(conn)
+	if err != nil || int(cred.Uid) != serverUID {
+		conn.Close()
+		return nil, fmt.Errorf("main socket is not served by the server (uid %d)", serverUID)
+	}
+
+	enc := json.NewEncoder(conn)
+	if err := enc.Encode(bridge.Handshake{Secret: sess.BridgeSecret}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := enc.Encode(bridge.BridgeHealthRequest{Type: "events", Session: sess.SessionID}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	var resp bridge.BridgeHealthResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("server did not accept the event stream: %w", err)
+	}
+	if resp.Status != "ok" {
+		conn.Close()
+		return nil, fmt.Errorf("server refused the event stream: %s", resp.Message)
+	}
+	return conn, nil
+}
+
+// retry runs fn until ctx ends, waiting longer after each consecutive failure.
+// A run that lasted a while resets the delay.
+func retry(ctx context.Context, name string, fn func(context.Context) error) {
+	delay := retryMin
+	for ctx.Err() == nil {
+		started := time.Now()
+		err := fn(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) > retryMax {
+			delay = retryMin
+		}
+		logger.Warnf("⚠️ %s stopped, retrying in %s: %v", name, delay, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, retryMax)
+	}
+}
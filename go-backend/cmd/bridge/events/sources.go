@@ -0,0 +1,175 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"go-backend/internal/bridge"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/client"
+	"github.com/godbus/dbus/v5"
+)
+
+// StartSources starts watching systemd, Docker, NetworkManager and PackageKit.
+// Sources whose backend is missing keep retrying with back-off, so a daemon started
+// later is picked up.
+func StartSources(ctx context.Context) {
+	go retry(ctx, "systemd watcher", watchSystemd)
+	go retry(ctx, "docker watcher", watchDocker)
+	go retry(ctx, "NetworkManager watcher", watchNetworkManager)
+	go retry(ctx, "PackageKit watcher", watchPackageKit)
+}
+
+// watchSignals subscribes to the signals matching opts on a private system bus connection
+// and passes them to handle until ctx ends or the connection breaks. setup, if not nil,
+// runs before the match is added.
+func watchSignals(ctx context.Context, setup func(*dbus.Conn) error, handle func(*dbus.Signal), opts ...dbus.MatchOption) error {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if setup != nil {
+		if err := setup(conn); err != nil {
+			return err
+		}
+	}
+	if err := conn.AddMatchSignalContext(ctx, opts...); err != nil {
+		return err
+	}
+	signals := make(chan *dbus.Signal, 64)
+	conn.Signal(signals)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case sig, ok := <-signals:
+			if !ok {
+				return errors.New("system bus connection closed")
+			}
+			handle(sig)
+		}
+	}
+}
+
+// watchSystemd publishes unit state changes. systemd only emits them to subscribed clients.
+func watchSystemd(ctx context.Context) error {
+	subscribe := func(conn *dbus.Conn) error {
+		return conn.Object("org.freedesktop.systemd1", "/org/freedesktop/systemd1").
+			CallWithContext(ctx, "org.freedesktop.systemd1.Manager.Subscribe", 0).Err
+	}
+	return watchSignals(ctx, subscribe, func(sig *dbus.Signal) {
+		changed, ok := changedProps(sig, "org.freedesktop.systemd1.Unit")
+		if !ok {
+			return
+		}
+		active, hasActive := changed["ActiveState"]
+		sub, hasSub := changed["SubState"]
+		if !hasActive && !hasSub {
+			return
+		}
+		Publish(bridge.ChannelSystemdUnits, "state_changed", map[string]any{
+			"unit":         unitName(sig.Path),
+			"active_state": active.Value(),
+			"sub_state":    sub.Value(),
+		})
+	},
+		dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+		dbus.WithMatchMember("PropertiesChanged"),
+		dbus.WithMatchPathNamespace("/org/freedesktop/systemd1/unit"),
+	)
+}
+
+// watchNetworkManager publishes the global connectivity state and device state changes.
+func watchNetworkManager(ctx context.Context) error {
+	return watchSignals(ctx, nil, func(sig *dbus.Signal) {
+		switch sig.Name {
+		case "org.freedesktop.NetworkManager.StateChanged":
+			if len(sig.Body) == 1 {
+				Publish(bridge.ChannelNetwork, "state_changed", map[string]any{"state": sig.Body[0]})
+			}
+		case "org.freedesktop.NetworkManager.Device.StateChanged":
+			if len(sig.Body) == 3 {
+				Publish(bridge.ChannelNetwork, "device_state_changed", map[string]any{
+					"device":    string(sig.Path),
+					"new_state": sig.Body[0],
+					"old_state": sig.Body[1],
+					"reason":    sig.Body[2],
+				})
+			}
+		}
+	},
+		dbus.WithMatchSender("org.freedesktop.NetworkManager"),
+		dbus.WithMatchMember("StateChanged"),
+	)
+}
+
+// watchPackageKit publishes changes of the available updates. Install progress is published
+// by the InstallPackage command itself.
+func watchPackageKit(ctx context.Context) error {
+	return watchSignals(ctx, nil, func(sig *dbus.Signal) {
+		Publish(bridge.ChannelPackageKit, "updates_changed", nil)
+	},
+		dbus.WithMatchObjectPath("/org/freedesktop/PackageKit"),
+		dbus.WithMatchInterface("org.freedesktop.PackageKit"),
+		dbus.WithMatchMember("UpdatesChanged"),
+	)
+}
+
+// watchDocker publishes the Docker daemon's event stream.
+func watchDocker(ctx context.Context) error {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	msgs, errs := cli.Events(ctx, events.ListOptions{})
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errs:
+			return err
+		case msg := <-msgs:
+			Publish(bridge.ChannelDocker, string(msg.Type)+"."+string(msg.Action), map[string]any{
+				"id":         msg.Actor.ID,
+				"attributes": msg.Actor.Attributes,
+			})
+		}
+	}
+}
+
+// changedProps returns the changed properties of a PropertiesChanged signal for iface.
+func changedProps(sig *dbus.Signal, iface string) (map[string]dbus.Variant, bool) {
+	if len(sig.Body) < 2 {
+		return nil, false
+	}
+	if name, _ := sig.Body[0].(string); name != iface {
+		return nil, false
+	}
+	changed, ok := sig.Body[1].(map[string]dbus.Variant)
+	return changed, ok
+}
+
+// unitName decodes a systemd unit object path, in which bytes other than [A-Za-z0-9]
+// are escaped as _xx: /org/freedesktop/systemd1/unit/ssh_2eservice → ssh.service.
+func unitName(path dbus.ObjectPath) string {
+	escaped := string(path)[strings.LastIndexByte(string(path), '/')+1:]
+	var b strings.Builder
+	for i := 0; i < len(escaped); i++ {
+		if escaped[i] == '_' && i+2 < len(escaped) {
+			if c, err := strconv.ParseUint(escaped[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(escaped[i])
+	}
+	return b.String()
+}
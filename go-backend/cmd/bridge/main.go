@@ -2,14 +2,19 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"go-backend/cmd/bridge/capabilities"
 	"go-backend/cmd/bridge/cleanup"
 	"go-backend/cmd/bridge/dbus"
 	"go-backend/cmd/bridge/docker"
+	"go-backend/cmd/bridge/events"
 	"go-backend/cmd/bridge/system"
 	"go-backend/internal/bridge"
 	"go-backend/internal/logger"
+	"go-backend/internal/rbac"
 	"go-backend/internal/session"
 	"go-backend/internal/theme"
 	"go-backend/internal/utils"
@@ -18,100 +23,97 @@ import (
 	"os"
 	"os/exec"
 	"os/user"
-	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	godbus "github.com/godbus/dbus/v5"
 	"github.com/google/uuid"
 )
 
-// Build minimal session object
+// Build minimal session object; the session ID, bridge identity and permissions come from the bootstrap
 var Sess = &session.Session{
-	SessionID: os.Getenv("LINUXIO_SESSION_ID"),
-	User:      utils.User{ID: os.Getenv("LINUXIO_SESSION_USER"), Name: os.Getenv("LINUXIO_SESSION_USER")},
+	User: utils.User{ID: os.Getenv("LINUXIO_SESSION_USER"), Name: os.Getenv("LINUXIO_SESSION_USER")},
 	// If you want, also read and set .Privileged from another env var
 }
 
-// Request represents the standard JSON request format sent to both built-in handlers and external helpers.
-type Request struct {
-	Type    string   `json:"type"`
-	Command string   `json:"command"`
-	Args    []string `json:"args,omitempty"`
-}
-
-// Response represents the standard JSON response returned by both built-in handlers and helpers.
-type Response struct {
-	Status string `json:"status"`
-	Output any    `json:"output,omitempty"`
-	Error  string `json:"error,omitempty"`
-}
-
 // HandlerFunc is the function signature for all built-in command handlers.
-type HandlerFunc func(args []string) (any, error)
+// Returning a *bridge.Error picks the error code the server sees; other errors are "internal".
+type HandlerFunc func(ctx context.Context, args []string) (any, error)
+
+// StreamHandlerFunc is a HandlerFunc for long-running commands that report progress before their result.
+type StreamHandlerFunc func(ctx context.Context, args []string, progress func(bridge.Progress)) (any, error)
 
 var shutdownChan = make(chan string, 1) // buffered, avoid blocking
 
+// The server allowed to connect. Its PID changes when the server restarts; a new server is
+// accepted once the old one is gone and it has proven itself with the bridge secret.
+var (
+	serverMu  sync.Mutex
+	serverUID int
+	serverPID int
+)
+
+// Per-command deadlines configured on the server (bridge.timeouts in serverConfig.yaml)
+var timeouts = bridge.ParseTimeouts(os.Getenv(bridge.TimeoutsEnv))
+
 // ---- Built-in Handler Registration ----
 // -- D-Bus Handlers --
 var dbusHandlers = map[string]HandlerFunc{
-	"Reboot":         func(args []string) (any, error) { return nil, dbus.CallLogin1Action("Reboot") },
-	"PowerOff":       func(args []string) (any, error) { return nil, dbus.CallLogin1Action("PowerOff") },
-	"GetUpdates":     func(args []string) (any, error) { return dbus.GetUpdatesWithDetails() },
-	"InstallPackage": func(args []string) (any, error) { return nil, dbus.InstallPackage(args[0]) },
-	"ListServices":   func(args []string) (any, error) { return dbus.ListServices() },
-	"GetServiceInfo": func(args []string) (any, error) { return dbus.GetServiceInfo(args[0]) },
-	"StartService":   func(args []string) (any, error) { return nil, dbus.StartService(args[0]) },
-	"StopService":    func(args []string) (any, error) { return nil, dbus.StopService(args[0]) },
-	"RestartService": func(args []string) (any, error) { return nil, dbus.RestartService(args[0]) },
-	"ReloadService":  func(args []string) (any, error) { return nil, dbus.ReloadService(args[0]) },
-	"EnableService":  func(args []string) (any, error) { return nil, dbus.EnableService(args[0]) },
-	"DisableService": func(args []string) (any, error) { return nil, dbus.DisableService(args[0]) },
-	"MaskService":    func(args []string) (any, error) { return nil, dbus.MaskService(args[0]) },
-	"UnmaskService":  func(args []string) (any, error) { return nil, dbus.UnmaskService(args[0]) },
-	"GetNetworkInfo": func(args []string) (any, error) { return dbus.GetNetworkInfo() },
-	"SetDNS":         func(args []string) (any, error) { return nil, dbus.SetDNS(args[0], args[1:]) },
-	"SetGateway":     func(args []string) (any, error) { return nil, dbus.SetGateway(args[0], args[1]) },
-	"SetMTU":         func(args []string) (any, error) { return nil, dbus.SetMTU(args[0], args[1]) },
-	"SetIPv4": func(args []string) (any, error) {
-		if len(args) < 2 {
-			return nil, fmt.Errorf("SetIPv4 requires interface and method (dhcp/static)")
+	"Reboot": func(ctx context.Context, args []string) (any, error) {
+		return nil, dbus.CallLogin1Action(ctx, "Reboot")
+	},
+	"PowerOff": func(ctx context.Context, args []string) (any, error) {
+		return nil, dbus.CallLogin1Action(ctx, "PowerOff")
+	},
+	"GetUpdates":     func(ctx context.Context, args []string) (any, error) { return dbus.GetUpdatesWithDetails(ctx) },
+	"ListServices":   func(ctx context.Context, args []string) (any, error) { return dbus.ListServices(ctx) },
+	"GetServiceInfo": func(ctx context.Context, args []string) (any, error) { return dbus.GetServiceInfo(ctx, args[0]) },
+	"StartService":   func(ctx context.Context, args []string) (any, error) { return nil, dbus.StartService(ctx, args[0]) },
+	"StopService":    func(ctx context.Context, args []string) (any, error) { return nil, dbus.StopService(ctx, args[0]) },
+	"RestartService": func(ctx context.Context, args []string) (any, error) { return nil, dbus.RestartService(ctx, args[0]) },
+	"ReloadService":  func(ctx context.Context, args []string) (any, error) { return nil, dbus.ReloadService(ctx, args[0]) },
+	"EnableService":  func(ctx context.Context, args []string) (any, error) { return nil, dbus.EnableService(ctx, args[0]) },
+	"DisableService": func(ctx context.Context, args []string) (any, error) { return nil, dbus.DisableService(ctx, args[0]) },
+	"MaskService":    func(ctx context.Context, args []string) (any, error) { return nil, dbus.MaskService(ctx, args[0]) },
+	"UnmaskService":  func(ctx context.Context, args []string) (any, error) { return nil, dbus.UnmaskService(ctx, args[0]) },
+	"GetNetworkInfo": func(ctx context.Context, args []string) (any, error) { return dbus.GetNetworkInfo(ctx) },
+	"SetDNS":         func(ctx context.Context, args []string) (any, error) { return nil, dbus.SetDNS(ctx, args[0], args[1:]) },
+	"SetGateway": func(ctx context.Context, args []string) (any, error) {
+		return nil, dbus.SetGateway(ctx, args[0], args[1])
+	},
+	"SetMTU": func(ctx context.Context, args []string) (any, error) { return nil, dbus.SetMTU(ctx, args[0], args[1]) },
+	"SetIPv4": func(ctx context.Context, args []string) (any, error) {
+		if args[1] == "dhcp" {
+			return nil, dbus.SetIPv4DHCP(ctx, args[0])
 		}
-		iface, method := args[0], strings.ToLower(args[1])
-		switch method {
-		case "dhcp":
-			return nil, dbus.SetIPv4DHCP(iface)
-		case "static":
-			if len(args) != 3 {
-				return nil, fmt.Errorf("SetIPv4 static requires addressCIDR")
-			}
-			return nil, dbus.SetIPv4Static(iface, args[2])
-		default:
-			return nil, fmt.Errorf("SetIPv4 method must be 'dhcp' or 'static'")
+		if len(args) != 3 {
+			return nil, bridge.Errorf(bridge.CodeInvalidArgs, "SetIPv4 static requires addressCIDR")
 		}
+		return nil, dbus.SetIPv4Static(ctx, args[0], args[2])
 	},
-	"SetIPv6": func(args []string) (any, error) {
-		if len(args) < 2 {
-			return nil, fmt.Errorf("SetIPv6 requires interface and method (dhcp/static)")
+	"SetIPv6": func(ctx context.Context, args []string) (any, error) {
+		if args[1] == "dhcp" {
+			return nil, dbus.SetIPv6DHCP(ctx, args[0])
 		}
-		iface, method := args[0], strings.ToLower(args[1])
-		switch method {
-		case "dhcp":
-			return nil, dbus.SetIPv6DHCP(iface)
-		case "static":
-			if len(args) != 3 {
-				return nil, fmt.Errorf("SetIPv6 static requires addressCIDR")
-			}
-			return nil, dbus.SetIPv6Static(iface, args[2])
-		default:
-			return nil, fmt.Errorf("SetIPv6 method must be 'dhcp' or 'static'")
+		if len(args) != 3 {
+			return nil, bridge.Errorf(bridge.CodeInvalidArgs, "SetIPv6 static requires addressCIDR")
 		}
+		return nil, dbus.SetIPv6Static(ctx, args[0], args[2])
 	},
 }
 
 // -- Control Handlers --
 var controlHandlers = map[string]HandlerFunc{
-	"shutdown": func(args []string) (any, error) {
+	"hello": func(ctx context.Context, args []string) (any, error) {
+		return bridge.HelloResult{Version: bridge.ProtocolVersion, MinVersion: bridge.MinProtocolVersion}, nil
+	},
+	"shutdown": func(ctx context.Context, args []string) (any, error) {
 		logger.Infof("Received shutdown command, exiting bridge")
 		select {
 		case shutdownChan <- "Bridge received shutdown command":
@@ -121,35 +123,49 @@ var controlHandlers = map[string]HandlerFunc{
 		}
 		return "Bridge shutting down", nil
 	},
+	"capabilities": func(ctx context.Context, args []string) (any, error) {
+		refresh := len(args) > 0 && args[0] == "true"
+		return capabilities.Get(ctx, refresh), nil
+	},
 }
 
 // -- System Handlers --
 var systemHandlers = map[string]HandlerFunc{
-	"get_drive_info": func(args []string) (any, error) {
-		return system.FetchDriveInfo()
+	"get_drive_info": func(ctx context.Context, args []string) (any, error) {
+		return system.FetchDriveInfo(ctx)
 	},
-	"get_smart_info": func(args []string) (any, error) {
-		if len(args) < 1 {
-			return nil, fmt.Errorf("missing device argument")
-		}
-		return system.FetchSmartInfo(args[0])
+	"get_smart_info": func(ctx context.Context, args []string) (any, error) {
+		return system.FetchSmartInfo(ctx, args[0])
 	},
-	"get_nvme_power": func(args []string) (any, error) {
-		if len(args) < 1 {
-			return nil, fmt.Errorf("missing device argument")
-		}
-		return system.GetNVMePowerState(args[0])
+	"get_nvme_power": func(ctx context.Context, args []string) (any, error) {
+		return system.GetNVMePowerState(ctx, args[0])
 	},
 }
 
 // -- Docker Handlers --
 var dockerHandlers = map[string]HandlerFunc{
-	"list_containers":   func(args []string) (any, error) { return docker.ListContainers() },
-	"start_container":   func(args []string) (any, error) { return docker.StartContainer(args[0]) },
-	"stop_container":    func(args []string) (any, error) { return docker.StopContainer(args[0]) },
-	"remove_container":  func(args []string) (any, error) { return docker.RemoveContainer(args[0]) },
-	"restart_container": func(args []string) (any, error) { return docker.RestartContainer(args[0]) },
-	"list_images":       func(args []string) (any, error) { return docker.ListImages() },
+	"list_containers":   func(ctx context.Context, args []string) (any, error) { return docker.ListContainers(ctx) },
+	"start_container":   func(ctx context.Context, args []string) (any, error) { return docker.StartContainer(ctx, args[0]) },
+	"stop_container":    func(ctx context.Context, args []string) (any, error) { return docker.StopContainer(ctx, args[0]) },
+	"remove_container":  func(ctx context.Context, args []string) (any, error) { return docker.RemoveContainer(ctx, args[0]) },
+	"restart_container": func(ctx context.Context, args []string) (any, error) { return docker.RestartContainer(ctx, args[0]) },
+	"list_images":       func(ctx context.Context, args []string) (any, error) { return docker.ListImages(ctx) },
+}
+
+// -- Streaming Handlers --
+var streamHandlersByType = map[string]map[string]StreamHandlerFunc{
+	"dbus": {
+		"InstallPackage": func(ctx context.Context, args []string, progress func(bridge.Progress)) (any, error) {
+			return nil, dbus.InstallPackage(ctx, args[0], func(percent int, status string) {
+				progress(bridge.Progress{Percent: percent, Status: status})
+				events.Publish(bridge.ChannelPackageKit, "progress", map[string]any{
+					"package": args[0],
+					"percent": percent,
+					"status":  status,
+				})
+			})
+		},
+	},
 }
 
 // -- Handler groups by type (built-in, for backwards compatibility) --
@@ -158,15 +174,132 @@ var handlersByType = map[string]map[string]HandlerFunc{
 	"control": controlHandlers,
 	"system":  systemHandlers,
 	"docker":  dockerHandlers,
-	"modules": {}, // Placeholder for external helpers
+	"modules": {
+		"list": func(ctx context.Context, args []string) (any, error) { return modules, nil },
+	},
+}
+
+// ---- Argument Schemas ----
+// Every built-in command declares its arguments here. Requests are validated against the
+// schema before dispatch, so handlers can index args directly. Unlisted commands take no arguments.
+var (
+	serviceArg   = bridge.ArgSpec{Name: "service", Required: true, Pattern: `[\w.-]+\.service`, Doc: "systemd unit name"}
+	ifaceArg     = bridge.ArgSpec{Name: "interface", Required: true, Pattern: `[\w.:@-]{1,15}`, Doc: "network interface name"}
+	methodArg    = bridge.ArgSpec{Name: "method", Type: "enum", Required: true, Enum: []string{"dhcp", "static"}}
+	addressArg   = bridge.ArgSpec{Name: "address", Pattern: `[0-9a-fA-F:.]+/\d{1,3}`, Doc: "address in CIDR notation, static method only"}
+	containerArg = bridge.ArgSpec{Name: "container", Required: true, Pattern: `[a-zA-Z0-9][a-zA-Z0-9_.-]*`, Doc: "container ID or name"}
+)
+
+var argsByType = map[string]map[string][]bridge.ArgSpec{
+	"dbus": {
+		"GetServiceInfo": {serviceArg},
+		"StartService":   {serviceArg},
+		"StopService":    {serviceArg},
+		"RestartService": {serviceArg},
+		"ReloadService":  {serviceArg},
+		"EnableService":  {serviceArg},
+		"DisableService": {serviceArg},
+		"MaskService":    {serviceArg},
+		"UnmaskService":  {serviceArg},
+		"SetDNS": {ifaceArg,
+			{Name: "servers", Required: true, Variadic: true, Pattern: `[0-9a-fA-F:.]+`, Doc: "DNS server addresses"}},
+		"SetGateway": {ifaceArg, {Name: "gateway", Required: true, Pattern: `[0-9a-fA-F:.]+`}},
+		"SetMTU":     {ifaceArg, {Name: "mtu", Type: "int", Required: true}},
+		"SetIPv4":    {ifaceArg, methodArg, addressArg},
+		"SetIPv6":    {ifaceArg, methodArg, addressArg},
+		"InstallPackage": {
+			{Name: "package_id", Required: true, Pattern: `[^;\s]+(;[^;\s]*){3}`, Doc: "PackageKit package ID (name;version;arch;data)"}},
+	},
+	"control": {
+		"capabilities": {{Name: "refresh", Type: "bool", Doc: "probe again instead of returning the last report"}},
+	},
+	"system": {
+		"get_smart_info": {{Name: "device", Required: true, Pattern: `sd[a-z]|hd[a-z]|nvme\d+n\d+`, Doc: "disk device name, without /dev/"}},
+		"get_nvme_power": {{Name: "device", Required: true, Pattern: `nvme\d+(n\d+)?`, Doc: "NVMe device name, without /dev/"}},
+	},
+	"docker": {
+		"start_container":   {containerArg},
+		"stop_container":    {containerArg},
+		"remove_container":  {containerArg},
+		"restart_container": {containerArg},
+	},
+}
+
+// A schema for a command that doesn't exist, or one that doesn't compile, is a programming error.
+func init() {
+	// Registered here: the schema listing refers back to the handler maps
+	controlHandlers["schema"] = func(ctx context.Context, args []string) (any, error) { return commandSchemas(), nil }
+
+	for reqType, cmds := range argsByType {
+		for name, specs := range cmds {
+			_, builtin := handlersByType[reqType][name]
+			_, stream := streamHandlersByType[reqType][name]
+			if !builtin && !stream {
+				panic(fmt.Sprintf("argument schema for unknown command %s %s", reqType, name))
+			}
+			if err := bridge.CompileArgs(specs); err != nil {
+				panic(fmt.Sprintf("argument schema of %s %s: %v", reqType, name, err))
+			}
+		}
+	}
 }
 
-// modulesDir returns the modules directory, overridable via env for flexibility.
-func modulesDir() string {
-	if val := os.Getenv("LINUXIO_MODULES_DIR"); val != "" {
-		return val
+// commandSchemas lists every built-in and module command with its arguments.
+func commandSchemas() []bridge.CommandSchema {
+	var schemas []bridge.CommandSchema
+	add := func(reqType, name string, args []bridge.ArgSpec, streaming bool) {
+		if args == nil {
+			args = []bridge.ArgSpec{}
+		}
+		schemas = append(schemas, bridge.CommandSchema{Type: reqType, Command: name, Args: args,
+			Permission: rbac.CommandPermission(reqType, name), Streaming: streaming})
+	}
+	for reqType, group := range handlersByType {
+		for name := range group {
+			add(reqType, name, argsByType[reqType][name], false)
+		}
+	}
+	for reqType, group := range streamHandlersByType {
+		for name := range group {
+			add(reqType, name, argsByType[reqType][name], true)
+		}
+	}
+	for reqType, cmds := range moduleCommands {
+		for name, cmd := range cmds {
+			add(reqType, name, cmd.Args, false)
+			schemas[len(schemas)-1].Description = cmd.Description
+			schemas[len(schemas)-1].Module = cmd.module.Name
+		}
+	}
+	sort.Slice(schemas, func(i, j int) bool {
+		if schemas[i].Type != schemas[j].Type {
+			return schemas[i].Type < schemas[j].Type
+		}
+		return schemas[i].Command < schemas[j].Command
+	})
+	return schemas
+}
+
+// -- External helper modules, loaded from their manifests at startup --
+var (
+	modules        []*bridge.Manifest
+	moduleCommands = map[string]map[string]moduleCommand{} // type → command
+)
+
+type moduleCommand struct {
+	module *bridge.Manifest
+	bridge.ModuleCommand
+}
+
+func loadModules() {
+	modules = bridge.RegisterModules()
+	for _, m := range modules {
+		cmds := make(map[string]moduleCommand, len(m.Commands))
+		for name, cmd := range m.Commands {
+			cmds[name] = moduleCommand{module: m, ModuleCommand: cmd}
+		}
+		moduleCommands[m.Type] = cmds
 	}
-	return "/usr/lib/linuxio/modules"
 }
 
 func main() {
@@ -177,6 +310,14 @@ func main() {
 	verbose := os.Getenv("VERBOSE") == "true"
 	logger.Init(env, verbose)
 
+	boot, err := bridge.ReadBootstrap(os.Stdin)
+	if err != nil {
+		logger.Error.Fatalf("❌ Failed to read bootstrap: %v", err)
+	}
+	Sess.SessionID, Sess.BridgeID, Sess.BridgeSecret = boot.SessionID, boot.BridgeID, boot.Secret
+	Sess.Permissions = boot.Permissions
+	serverUID, serverPID = boot.ServerUID, boot.ServerPID
+
 	logger.Infof("📦 Checking for default configuration...")
 	if err := utils.EnsureStartupDefaults(); err != nil {
 		logger.Errorf("❌ Error setting config files: %v", err)
@@ -187,6 +328,16 @@ func main() {
 		logger.Errorf("❌ Failed to initialize theme file: %v", err)
 	}
 
+	logger.Infof("📦 Loading helper modules from %s...", bridge.ModulesDir())
+	loadModules()
+
+	// Probe backends in the background; the first capabilities request waits for it
+	go capabilities.Get(context.Background(), false)
+
+	// Push systemd, Docker, NetworkManager and PackageKit events to the server for websocket subscribers
+	go events.Run(context.Background(), Sess, serverUID)
+	events.StartSources(context.Background())
+
 	socketPath := bridge.BridgeSocketPath(Sess)
 	listener, _, _, err := createAndOwnSocket(socketPath, Sess.User.ID)
 	if err != nil {
@@ -199,7 +350,7 @@ func main() {
 		defer ticker.Stop()
 		for range ticker.C {
 			logToFile("Healthcheck: pinging main process")
-			ok := cleanup.CheckMainProcessHealth(Sess)
+			ok := cleanup.CheckMainProcessHealth(Sess, serverUID)
 			logToFile(fmt.Sprintf("Healthcheck result: %v", ok))
 			if !ok {
 				select {
@@ -303,74 +454,260 @@ func createAndOwnSocket(socketPath, username string) (net.Listener, int, int, er
 	return listener, uid, gid, nil
 }
 
-// handleConnection processes incoming bridge requests.
-// If the command is not built-in, dispatches to an external helper binary in the modules directory.
+// handleConnection serves one server connection. The server keeps the connection open and
+// sends many requests over it; each one is handled concurrently and answered with its ID,
+// so responses may come back out of order.
 func handleConnection(conn net.Conn, id string) {
 	logger.Debugf("HANDLECONNECTION: [%s] called!", id)
 	defer conn.Close()
 
+	peerPID, err := checkServerPeer(conn)
+	if err != nil {
+		logger.Warnf("❌ [%s] rejected connection: %v", id, err)
+		return
+	}
 	decoder := json.NewDecoder(conn)
 	encoder := json.NewEncoder(conn)
 
-	var req Request
-	if err := decoder.Decode(&req); err != nil {
-		if err == io.EOF {
-			logger.Debugf("🔁 [%s] connection closed without data (likely healthcheck probe)", id)
-		} else {
-			logger.Warnf("❌ [%s] invalid JSON from client: %v", id, err)
-		}
-		_ = encoder.Encode(Response{Status: "error", Error: "invalid JSON"})
+	// Liveness probes connect and hang up without a handshake
+	var hs bridge.Handshake
+	if err := decoder.Decode(&hs); err != nil {
+		logger.Debugf("[%s] connection closed before the handshake: %v", id, err)
+		return
+	}
+	if !bridge.SecretMatches(hs.Secret, Sess.BridgeSecret) {
+		logger.Warnf("❌ [%s] rejected connection from pid %d: bad secret", id, peerPID)
 		return
 	}
+	serverMu.Lock()
+	if serverPID != peerPID {
+		logger.Infof("🔑 [%s] server restarted, now pid %d", id, peerPID)
+		serverPID = peerPID
+	}
+	serverMu.Unlock()
+
+	var writeMu sync.Mutex
+	send := func(resp bridge.Response) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		if err := encoder.Encode(resp); err != nil {
+			logger.Warnf("❌ [%s] failed to write response %s: %v", id, resp.ID, err)
+		}
+	}
+
+	// Every request's context ends when the server disconnects or cancels it
+	connCtx, disconnect := context.WithCancel(context.Background())
+	defer disconnect()
+	var cancelsMu sync.Mutex
+	cancels := make(map[string]context.CancelFunc)
+
+	var inflight sync.WaitGroup
+	defer inflight.Wait()
+
+	for {
+		var req bridge.Request
+		if err := decoder.Decode(&req); err != nil {
+			if err == io.EOF {
+				logger.Debugf("🔁 [%s] connection closed by client", id)
+			} else {
+				// The stream can't be resynchronised after a decode error
+				logger.Warnf("❌ [%s] invalid JSON from client: %v", id, err)
+				send(bridge.ErrorResponse(req, bridge.Errorf(bridge.CodeInvalidArgs, "invalid JSON")))
+			}
+			return
+		}
+
+		if req.Type == "control" && req.Command == "cancel" {
+			if len(req.Args) == 1 {
+				cancelsMu.Lock()
+				cancel, ok := cancels[req.Args[0]]
+				cancelsMu.Unlock()
+				if ok {
+					logger.Infof("⏹️ [%s] request %s cancelled by the server", id, req.Args[0])
+					cancel()
+				}
+			}
+			continue
+		}
+
+		// Clients older than the streaming protocol only expect the final response
+		progress := func(bridge.Progress) {}
+		if req.Version >= bridge.StreamingVersion {
+			progress = func(p bridge.Progress) { send(bridge.ProgressResponse(req, p)) }
+		}
+
+		ctx, cancel := context.WithTimeout(connCtx, commandTimeout(req))
+		cancelsMu.Lock()
+		cancels[req.ID] = cancel
+		cancelsMu.Unlock()
+
+		inflight.Add(1)
+		go func() {
+			defer inflight.Done()
+			defer func() {
+				cancelsMu.Lock()
+				delete(cancels, req.ID)
+				cancelsMu.Unlock()
+				cancel()
+			}()
+			send(handleRequest(ctx, req, id, progress))
+		}()
+	}
+}
+
+// checkServerPeer accepts connections from the server only: its UID, and its PID unless
+// that process has exited.
+func checkServerPeer(conn net.Conn) (int, error) {
+	cred, err := bridge.PeerCred(conn)
+	if err != nil {
+		return 0, err
+	}
+	if int(cred.Uid) != serverUID {
+		return 0, fmt.Errorf("peer uid %d is not the server's (%d)", cred.Uid, serverUID)
+	}
+	serverMu.Lock()
+	expected := serverPID
+	serverMu.Unlock()
+	if int(cred.Pid) != expected && processAlive(expected) {
+		return 0, fmt.Errorf("peer pid %d is not the server (pid %d)", cred.Pid, expected)
+	}
+	return int(cred.Pid), nil
+}
+
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// commandTimeout is the deadline of a request, looked up as the server does.
+func commandTimeout(req bridge.Request) time.Duration {
+	return timeouts.CommandTimeout(req.Type, req.Command)
+}
+
+// handleRequest runs a single request, reporting progress of streaming commands through progress.
+// Handlers must give up when ctx ends (deadline, server cancel or disconnect).
+// If the command is not built-in, dispatches to the helper module declaring it.
+func handleRequest(ctx context.Context, req bridge.Request, id string, progress func(bridge.Progress)) (resp bridge.Response) {
+	if req.Version < bridge.MinProtocolVersion || req.Version > bridge.ProtocolVersion {
+		logger.Warnf("❌ [%s] unsupported protocol version %d", id, req.Version)
+		return bridge.ErrorResponse(req, bridge.Errorf(bridge.CodeUnsupportedVersion,
+			"unsupported protocol version %d (supported %d-%d)", req.Version, bridge.MinProtocolVersion, bridge.ProtocolVersion))
+	}
 
 	// (1) DEFENSE-IN-DEPTH: Validate handler name for fallback
 	if strings.ContainsAny(req.Type, "./\\") || strings.ContainsAny(req.Command, "./\\") {
 		logger.Warnf("❌ [%s] Invalid characters in type/command: type=%q, command=%q", id, req.Type, req.Command)
-		_ = encoder.Encode(Response{Status: "error", Error: "invalid characters in command/type"})
-		return
+		return bridge.ErrorResponse(req, bridge.Errorf(bridge.CodeInvalidArgs, "invalid characters in command/type"))
 	}
 
-	logger.Infof("➡️ Received request: type=%s, command=%s, args=%v", req.Type, req.Command, req.Args)
+	logger.Infof("➡️ Received request %s: type=%s, command=%s, args=%v", req.ID, req.Type, req.Command, req.Args)
+
+	// The server checks permissions too; this keeps a direct socket client to the session's roles
+	if perm := rbac.CommandPermission(req.Type, req.Command); !rbac.Has(Sess.Permissions, perm) {
+		logger.Warnf("❌ [%s] %s %s denied for user %s (missing %s)", id, req.Type, req.Command, Sess.User.ID, perm)
+		return bridge.ErrorResponse(req, bridge.Errorf(bridge.CodePermissionDenied, "permission denied: %s", perm))
+	}
 
 	// (2) Avoid nil map panic and clarify intent
-	group, found := handlersByType[req.Type]
-	if found && group != nil {
-		if handler, ok := group[req.Command]; ok {
-			defer func() {
-				if r := recover(); r != nil {
-					logger.Errorf("🔥 Panic in %s command handler: %v", req.Type, r)
-					_ = encoder.Encode(Response{Status: "error", Error: fmt.Sprintf("panic: %v", r)})
-				}
-			}()
-			out, err := handler(req.Args)
-			if err == nil {
-				_ = encoder.Encode(Response{Status: "ok", Output: out})
-				return
+	var handler HandlerFunc
+	if stream, ok := streamHandlersByType[req.Type][req.Command]; ok {
+		handler = func(ctx context.Context, args []string) (any, error) { return stream(ctx, args, progress) }
+	} else if group, found := handlersByType[req.Type]; found && group != nil {
+		handler = group[req.Command]
+	}
+	if handler != nil {
+		if err := bridge.ValidateArgs(argsByType[req.Type][req.Command], req.Args); err != nil {
+			logger.Warnf("❌ [%s] %s %s: %v", id, req.Type, req.Command, err)
+			return bridge.ErrorResponse(req, err)
+		}
+		defer func() {
+			if r := recover(); r != nil {
+				logger.Errorf("🔥 Panic in %s command handler: %v", req.Type, r)
+				resp = bridge.ErrorResponse(req, bridge.Errorf(bridge.CodeInternal, "panic: %v", r))
 			}
-			logger.Errorf("❌ %s %s failed: %v", req.Type, req.Command, err)
-			_ = encoder.Encode(Response{Status: "error", Error: err.Error()})
-			return
+		}()
+		out, err := handler(ctx, req.Args)
+		if err == nil {
+			return bridge.OKResponse(req, out)
 		}
+		err = classifyError(ctx, err)
+		logger.Errorf("❌ %s %s failed (%s): %v", req.Type, req.Command, bridge.ErrorCodeOf(err), err)
+		return bridge.ErrorResponse(req, err)
 	}
 
-	// Fallback: try running external helper in modulesDir (modular extension point)
-	helperPath := filepath.Join(modulesDir(), fmt.Sprintf("%s_%s", req.Type, req.Command))
-	info, err := os.Stat(helperPath)
-	if err == nil && !info.IsDir() && info.Mode()&0111 != 0 {
-		logger.Infof("🔎 Dispatching to helper: %s", helperPath)
-		runHelper(helperPath, req, encoder)
-		return
+	// Fallback: a command declared by a helper module (modular extension point)
+	if cmd, ok := moduleCommands[req.Type][req.Command]; ok {
+		if err := bridge.ValidateArgs(cmd.Args, req.Args); err != nil {
+			logger.Warnf("❌ [%s] %s %s: %v", id, req.Type, req.Command, err)
+			return bridge.ErrorResponse(req, err)
+		}
+		if cmd.Privileged && os.Geteuid() != 0 {
+			return bridge.ErrorResponse(req, bridge.Errorf(bridge.CodePermissionDenied, "%s %s requires a privileged session", req.Type, req.Command))
+		}
+		// The files may have changed since the module was loaded
+		helperPath, err := cmd.module.ExecPath(req.Command)
+		if err != nil {
+			logger.Errorf("❌ [%s] refusing to run helper of module %s: %v", id, cmd.module.Name, err)
+			return bridge.ErrorResponse(req, bridge.Errorf(bridge.CodePermissionDenied, "helper of module %s is not trusted", cmd.module.Name))
+		}
+		logger.Infof("🔎 Dispatching to helper: %s (module %s %s)", helperPath, cmd.module.Name, cmd.module.Version)
+		return runHelper(ctx, helperPath, req)
 	}
 
 	logger.Warnf("❌ Unknown command for type %s: %s", req.Type, req.Command)
-	_ = encoder.Encode(Response{Status: "error", Error: fmt.Sprintf("unknown command: %s", req.Command)})
+	return bridge.ErrorResponse(req, bridge.Errorf(bridge.CodeNotFound, "unknown command: %s", req.Command))
+}
+
+// classifyError gives well-known D-Bus and Docker failures, and failures caused by
+// the request's deadline or cancellation, their bridge error code.
+func classifyError(ctx context.Context, err error) error {
+	var be *bridge.Error
+	if errors.As(err, &be) {
+		return err
+	}
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return &bridge.Error{Code: bridge.CodeTimeout, Message: err.Error()}
+	case context.Canceled:
+		return &bridge.Error{Code: bridge.CodeCanceled, Message: err.Error()}
+	}
+
+	var dbusErr godbus.Error
+	if errors.As(err, &dbusErr) {
+		switch dbusErr.Name {
+		case "org.freedesktop.systemd1.NoSuchUnit", "org.freedesktop.DBus.Error.UnknownObject", "org.freedesktop.DBus.Error.UnknownMethod":
+			return &bridge.Error{Code: bridge.CodeNotFound, Message: err.Error()}
+		case "org.freedesktop.DBus.Error.AccessDenied", "org.freedesktop.PolicyKit1.Error.NotAuthorized", "org.freedesktop.DBus.Error.InteractiveAuthorizationRequired":
+			return &bridge.Error{Code: bridge.CodePermissionDenied, Message: err.Error()}
+		case "org.freedesktop.DBus.Error.InvalidArgs":
+			return &bridge.Error{Code: bridge.CodeInvalidArgs, Message: err.Error()}
+		case "org.freedesktop.DBus.Error.NoReply", "org.freedesktop.DBus.Error.Timeout", "org.freedesktop.DBus.Error.TimedOut":
+			return &bridge.Error{Code: bridge.CodeTimeout, Message: err.Error()}
+		case "org.freedesktop.DBus.Error.ServiceUnknown", "org.freedesktop.DBus.Error.NameHasNoOwner":
+			return &bridge.Error{Code: bridge.CodeUnavailable, Message: err.Error()}
+		}
+	}
+
+	switch {
+	case errdefs.IsNotFound(err):
+		return &bridge.Error{Code: bridge.CodeNotFound, Message: err.Error()}
+	case errdefs.IsInvalidParameter(err):
+		return &bridge.Error{Code: bridge.CodeInvalidArgs, Message: err.Error()}
+	case errdefs.IsForbidden(err), errdefs.IsUnauthorized(err):
+		return &bridge.Error{Code: bridge.CodePermissionDenied, Message: err.Error()}
+	case client.IsErrConnectionFailed(err), errdefs.IsUnavailable(err):
+		return &bridge.Error{Code: bridge.CodeUnavailable, Message: err.Error()}
+	case errors.Is(err, context.DeadlineExceeded), errdefs.IsDeadline(err):
+		return &bridge.Error{Code: bridge.CodeTimeout, Message: err.Error()}
+	}
+	return err
 }
 
 // runHelper executes an external helper script or binary, passing the entire Request as JSON on stdin,
 // and expects a JSON Response on stdout.
 // If the helper fails, its stderr output is included in the error response for diagnostics.
-// Malformed output is logged for troubleshooting.
-func runHelper(path string, req Request, encoder *json.Encoder) {
+// Malformed output is logged for troubleshooting. The helper is killed when ctx ends.
+func runHelper(ctx context.Context, path string, req bridge.Request) bridge.Response {
 	logger.Debugf("RUNHELPER: called for %s", path)
 
 	inputBytes, _ := json.Marshal(req)
@@ -380,32 +717,21 @@ func runHelper(path string, req Request, encoder *json.Encoder) {
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
 		logger.Errorf("Helper %s: failed to open stdin: %v", path, err)
-		_ = encoder.Encode(Response{Status: "error", Error: "failed to open stdin for helper"})
-		return
+		return bridge.ErrorResponse(req, bridge.Errorf(bridge.CodeInternal, "failed to open stdin for helper"))
 	}
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		logger.Errorf("Helper %s: failed to open stdout: %v", path, err)
-		_ = encoder.Encode(Response{Status: "error", Error: "failed to open stdout for helper"})
-		return
+		return bridge.ErrorResponse(req, bridge.Errorf(bridge.CodeInternal, "failed to open stdout for helper"))
 	}
 
 	var stderrBuf bytes.Buffer
 	cmd.Stderr = &stderrBuf
 
-	// (3) Helper timeout: configurable
-	timeout := 10 * time.Second
-	if val := os.Getenv("BRIDGE_HELPER_TIMEOUT"); val != "" {
-		if parsed, err := time.ParseDuration(val); err == nil {
-			timeout = parsed
-		}
-	}
-
 	if err := cmd.Start(); err != nil {
 		logger.Errorf("Helper %s: failed to start: %v", path, err)
-		_ = encoder.Encode(Response{Status: "error", Error: fmt.Sprintf("failed to start helper: %v", err)})
-		return
+		return bridge.ErrorResponse(req, bridge.Errorf(bridge.CodeUnavailable, "failed to start helper: %v", err))
 	}
 
 	// Write input
@@ -428,33 +754,39 @@ func runHelper(path string, req Request, encoder *json.Encoder) {
 	go func() { done <- cmd.Wait() }()
 
 	select {
-	case <-time.After(timeout): // <--- use timeout variable
+	case <-ctx.Done(): // (3) deadline from commandTimeout, or cancelled by the server
 		_ = cmd.Process.Kill()
-		logger.Errorf("Helper %s timed out.\n  STDOUT: %s\n  STDERR: %s", path, string(outBytes), stderrBuf.String())
-		_ = encoder.Encode(Response{Status: "error", Error: "helper timed out"})
-		return
+		if ctx.Err() == context.Canceled {
+			logger.Infof("Helper %s cancelled", path)
+			return bridge.ErrorResponse(req, bridge.Errorf(bridge.CodeCanceled, "helper cancelled"))
+		}
+		logger.Errorf("Helper %s timed out.\n  STDERR: %s", path, stderrBuf.String())
+		return bridge.ErrorResponse(req, bridge.Errorf(bridge.CodeTimeout, "helper timed out"))
 
 	case err := <-done:
 		<-stdoutDone // ensure output is fully read
 		logger.Debugf("Helper %s finished. Exit error: %v\n  STDOUT: %s\n  STDERR: %s",
 			path, err, string(outBytes), stderrBuf.String())
 		if err != nil {
-			_ = encoder.Encode(Response{Status: "error", Error: fmt.Sprintf("helper exited with error: %s", stderrBuf.String())})
-			return
+			return bridge.ErrorResponse(req, bridge.Errorf(bridge.CodeInternal, "helper exited with error: %s", stderrBuf.String()))
 		}
 	}
 
 	// DEBUG: decode response
 	logger.Debugf("DEBUG: About to decode helper output:\n=====\n%s\n=====", string(outBytes))
 
-	var resp Response
+	var resp bridge.Response
 	if err := json.Unmarshal(outBytes, &resp); err != nil {
 		logger.Infof("Helper %s output (malformed JSON):\n  STDOUT: %s\n  STDERR: %s", path, string(outBytes), stderrBuf.String())
-		_ = encoder.Encode(Response{Status: "error", Error: "invalid JSON from helper"})
-		return
+		return bridge.ErrorResponse(req, bridge.Errorf(bridge.CodeInternal, "invalid JSON from helper"))
 	}
 
-	_ = encoder.Encode(resp)
+	// Helpers may predate versioning; the envelope always answers the request
+	resp.Version, resp.ID = req.Version, req.ID
+	if resp.Status != "ok" && resp.Code == "" {
+		resp.Code = bridge.CodeInternal
+	}
+	return resp
 }
 
 func MainSocketPath(sess *session.Session) (string, error) {
@@ -463,7 +795,7 @@ func MainSocketPath(sess *session.Session) (string, error) {
 		logger.Errorf("could not find user %s: %v", sess.User.ID, err)
 		return "", err
 	}
-	return fmt.Sprintf("/run/user/%s/linuxio-main-%s.sock", u.Uid, sess.SessionID), nil
+	return fmt.Sprintf("/run/user/%s/linuxio-main-%s.sock", u.Uid, sess.BridgeID), nil
 }
 
 func BridgeSocketPath(sess *session.Session) (string, error) {
@@ -472,16 +804,34 @@ func BridgeSocketPath(sess *session.Session) (string, error) {
 		logger.Errorf("could not find user %s: %v", sess.User.ID, err)
 		return "", err
 	}
-	return fmt.Sprintf("/run/user/%s/linuxio-bridge-%s.sock", u.Uid, sess.SessionID), nil
+	return fmt.Sprintf("/run/user/%s/linuxio-bridge-%s.sock", u.Uid, sess.BridgeID), nil
 }
 
 /*
 Auto-docs & Contributor note:
 
-- To add a new bridge extension, drop an executable into the modules directory (default /usr/lib/linuxio/modules or override with LINUXIO_MODULES_DIR env).
-- Name helpers as <type>_<command> (e.g., system_myfeature), chmod +x.
+- To add a new bridge extension, create a directory in the modules directory (default /usr/lib/linuxio/modules or override with LINUXIO_MODULES_DIR env)
+  holding a manifest.yaml and the helper executables it names. Everything must be owned by root and not group/world-writable, or the module is skipped.
+- Example manifest.yaml:
+    name: backup
+    version: 1.0.0
+    description: Snapshot management
+    commands:
+      snapshot:
+        description: Take a snapshot of a volume
+        exec: snapshot.sh
+        permission: system.manage   # RBAC permission; admin-only when omitted
+        privileged: true            # only runs in a privileged (sudo) bridge
+        timeout: 5m
+        args:
+          - {name: volume, required: true, pattern: "[a-z0-9-]+"}
+          - {name: mode, type: enum, enum: [full, incremental]}
+- Requests use the module name as type (override with "type:"); built-in types can't be claimed. Arguments are validated against "args" before the helper runs.
+- The loaded manifests are listed by the modules/list command and GET /bridge/modules.
 - Helper receives full Request JSON on stdin, must return a Response JSON on stdout.
-- Example helper input: {"type":"system","command":"myfeature","args":["foo"]}
-- Example helper output: {"status":"ok", "output":{...}}, or {"status":"error","error":"explanation"}
+- Example helper input: {"version":1,"id":"...","type":"backup","command":"snapshot","args":["root"]}
+- Example helper output: {"status":"ok", "output":{...}}, or {"status":"error","error":"explanation","code":"not_found"}
+- Error codes: not_found, permission_denied, invalid_args, timeout, canceled, unavailable, internal (the default).
+- Helpers are killed at the command's deadline (bridge.timeouts in serverConfig.yaml, the manifest's timeout, or BRIDGE_HELPER_TIMEOUT).
 - stderr from helpers is logged and returned on error for troubleshooting.
 */
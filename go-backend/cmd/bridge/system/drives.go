@@ -1,6 +1,7 @@
 package system
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -39,8 +40,8 @@ type InferredPowerData struct {
 	States       []PowerStateInfo `json:"states"`
 }
 
-func FetchDriveInfo() ([]map[string]any, error) {
-	out, err := exec.Command("lsblk", "-d", "-O", "-J").Output()
+func FetchDriveInfo(ctx context.Context) ([]map[string]any, error) {
+	out, err := exec.CommandContext(ctx, "lsblk", "-d", "-O", "-J").Output()
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute lsblk: %w", err)
 	}
@@ -67,7 +68,7 @@ func FetchDriveInfo() ([]map[string]any, error) {
 		}
 
 		// Add SMART info for all drives
-		smart, err := FetchSmartInfo(dev.Name)
+		smart, err := FetchSmartInfo(ctx, dev.Name)
 		if err != nil {
 			drive["smartError"] = err.Error()
 		} else {
@@ -76,7 +77,7 @@ func FetchDriveInfo() ([]map[string]any, error) {
 
 		// Add NVMe power info if NVMe
 		if dev.Tran == "nvme" {
-			power, err := GetNVMePowerState(dev.Name)
+			power, err := GetNVMePowerState(ctx, dev.Name)
 			if err != nil {
 				drive["powerError"] = err.Error()
 			} else {
@@ -102,7 +103,7 @@ func FetchDriveInfo() ([]map[string]any, error) {
 	return drives, nil
 }
 
-func FetchSmartInfo(device string) (map[string]any, error) {
+func FetchSmartInfo(ctx context.Context, device string) (map[string]any, error) {
 	validName := regexp.MustCompile(`^(sd[a-z]|hd[a-z]|nvme\d+n\d+)$`)
 	if !validName.MatchString(device) {
 		return nil, errors.New("invalid device name")
@@ -113,7 +114,7 @@ func FetchSmartInfo(device string) (map[string]any, error) {
 		return nil, fmt.Errorf("smartctl not found: %w", err)
 	}
 
-	cmd := exec.Command(smartctlPath, "--json", "-x", "/dev/"+device)
+	cmd := exec.CommandContext(ctx, smartctlPath, "--json", "-x", "/dev/"+device)
 	out, err := cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("smartctl failed: %w", err)
@@ -127,9 +128,9 @@ func FetchSmartInfo(device string) (map[string]any, error) {
 	return parsed, nil
 }
 
-func GetNVMePowerState(device string) (*InferredPowerData, error) {
+func GetNVMePowerState(ctx context.Context, device string) (*InferredPowerData, error) {
 	// Step 1: Get supported power states
-	cmd := exec.Command("nvme", "id-ctrl", "/dev/"+device)
+	cmd := exec.CommandContext(ctx, "nvme", "id-ctrl", "/dev/"+device)
 	out, err := cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("failed to run nvme id-ctrl: %w", err)
@@ -155,7 +156,7 @@ func GetNVMePowerState(device string) (*InferredPowerData, error) {
 	}
 
 	// Step 2: Get current power state
-	cmd = exec.Command("nvme", "smart-log", "/dev/"+device)
+	cmd = exec.CommandContext(ctx, "nvme", "smart-log", "/dev/"+device)
 	out, err = cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("failed to run nvme smart-log: %w", err)
@@ -1,13 +1,17 @@
 package main
 
 import (
-	"crypto/tls"
 	embed "go-backend"
 	"go-backend/cmd/server/docker"
 	"go-backend/internal/auth"
 	"go-backend/internal/benchmark"
+	"go-backend/internal/bridge"
+	"go-backend/internal/bridgeapi"
+	"go-backend/internal/certificates"
+	"go-backend/internal/config"
 	"go-backend/internal/dockers"
 	"go-backend/internal/logger"
+	"go-backend/internal/metrics"
 	"go-backend/internal/networks"
 	"go-backend/internal/power"
 	"go-backend/internal/services"
@@ -17,7 +21,6 @@ import (
 	"go-backend/internal/theme"
 	"go-backend/internal/updates"
 
-	"go-backend/internal/utils"
 	"go-backend/internal/websocket"
 	"net/http"
 	"os"
@@ -40,12 +43,44 @@ func main() {
 
 	logger.Infof("🌱 Starting server in %s mode...", env)
 
+	logger.Infof("📦 Loading server configuration...")
+	if err := config.LoadServerConfig(); err != nil {
+		logger.Errorf("❌ Failed to load server config, using defaults: %v", err)
+	}
+
+	// Module commands declare their own permissions, which the WebSocket bridge calls check
+	logger.Infof("📦 Loading helper modules from %s...", bridge.ModulesDir())
+	bridge.RegisterModules()
+
 	go docker.StartServices()
 
 	if !verbose {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	sessionCfg := config.GetServerConfig().Session
+	session.SetTimeouts(sessionCfg.IdleTimeout.Std(), sessionCfg.MaxLifetime.Std())
+
+	// Restore persisted sessions and re-attach to their still-running bridges
+	store, err := session.NewStoreFromEnv()
+	if err == nil {
+		err = session.Init(store)
+	}
+	if err != nil {
+		logger.Errorf("❌ Failed to load session store, falling back to in-memory sessions: %v", err)
+		_ = session.Init(session.MemoryStore{})
+	}
+	// Host events pushed by bridges go to the owning session's channel subscribers
+	bridge.SetEventHook(websocket.PublishBridgeEvent)
+	// Metrics channels sample only while someone is subscribed
+	metrics.RegisterPublishers()
+	// Clients learn about bridge crashes and restarts over their websocket
+	bridge.SetStatusHook(func(sessionID string, st bridge.Status) {
+		websocket.NotifySession(sessionID, "bridge_status", st)
+	})
+	bridge.ReattachSessions()
+	auth.ResumeElevationTimers()
+
 	// Start the session garbage collector
 	session.StartSessionGC()
 	// Initialize cache functions
@@ -53,9 +88,10 @@ func main() {
 
 	router := gin.New()
 	router.Use(gin.Recovery())
+	// Never trust X-Forwarded-For: ClientIP feeds the login lockout
+	router.SetTrustedProxies(nil)
 
 	if env == "development" {
-		router.SetTrustedProxies(nil)
 		router.Use(auth.CorsMiddleware())
 		router.Use(gin.Logger())
 	}
@@ -70,6 +106,8 @@ func main() {
 	dockers.RegisterDockerComposeRoutes(router)
 	theme.RegisterThemeRoutes(router)
 	power.RegisterPowerRoutes(router)
+	certificates.RegisterCertificateRoutes(router)
+	bridgeapi.RegisterBridgeRoutes(router)
 	// API Benchmark route
 	if env != "production" {
 		benchmark.RegisterDebugRoutes(router, env)
@@ -103,18 +141,18 @@ func main() {
 	addr := ":" + port
 
 	if env == "production" {
-		cert, err := utils.GenerateSelfSignedCert()
-		if err != nil {
-			logger.Error.Fatalf("❌ Failed to generate self-signed certificate: %v", err)
+		if err := certificates.Init(); err != nil {
+			logger.Error.Fatalf("❌ Failed to load TLS certificate: %v", err)
 		}
+		certificates.WatchSIGHUP()
 
 		srv := &http.Server{
 			Addr:      addr,
 			Handler:   router,
-			TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+			TLSConfig: certificates.ServerTLSConfig(),
 		}
 		logger.Infof("🚀 Server running at https://localhost:%s", port)
-		logger.Error.Fatal(srv.ListenAndServeTLS("", "")) // Empty filenames = use TLSConfig.Certificates
+		logger.Error.Fatal(srv.ListenAndServeTLS("", "")) // Empty filenames = use TLSConfig.GetCertificate
 	} else {
 		logger.Infof("🚀 Server running at http://localhost:%s", port)
 		logger.Error.Fatal(router.Run(addr))
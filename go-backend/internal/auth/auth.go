@@ -3,9 +3,11 @@ package auth
 import (
 	"bytes"
 	"context"
+	"errors"
 	"go-backend/internal/bridge"
 	"go-backend/internal/config"
 	"go-backend/internal/logger"
+	"go-backend/internal/rbac"
 	"go-backend/internal/session"
 	"go-backend/internal/utils"
 	"io"
@@ -17,7 +19,6 @@ import (
 	"github.com/docker/docker/client"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/msteinert/pam"
 )
 
 type LoginRequest struct {
@@ -25,8 +26,6 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
-const sessionDuration = 6 * time.Hour
-
 var (
 	dockerCli *client.Client
 	dockerCtx context.Context
@@ -41,19 +40,38 @@ func RegisterAuthRoutes(router *gin.Engine) {
 	auth := router.Group("/auth")
 	{
 		auth.POST("/login", loginHandler)
+		auth.POST("/login/respond", loginRespondHandler)
+		auth.POST("/login/2fa", loginTwoFactorHandler)
+		auth.POST("/login/certificate", certificateLoginHandler)
 		auth.GET("/me", AuthMiddleware(), meHandler)
-		auth.GET("/logout", AuthMiddleware(), logoutHandler)
-	}
-}
+		auth.POST("/logout", AuthMiddleware(), logoutHandler)
+		auth.POST("/elevate", AuthMiddleware(), elevateHandler)
+		auth.POST("/drop-privileges", AuthMiddleware(), dropPrivilegesHandler)
 
-func pamAuth(username, password string) error {
-	t, err := pam.StartFunc("login", username, func(s pam.Style, msg string) (string, error) {
-		return password, nil
-	})
-	if err != nil {
-		return err
+		twoFactor := auth.Group("/2fa", AuthMiddleware())
+		twoFactor.GET("/status", twoFactorStatusHandler)
+		twoFactor.POST("/setup", twoFactorSetupHandler)
+		twoFactor.POST("/confirm", twoFactorConfirmHandler)
+		twoFactor.POST("/disable", twoFactorDisableHandler)
+
+		settings := auth.Group("/settings", AuthMiddleware(), RequirePrivileged(), RequirePermission(rbac.PermSettingsManage))
+		settings.GET("", getAuthSettingsHandler)
+		settings.POST("", postAuthSettingsHandler)
+
+		sessions := auth.Group("/sessions", AuthMiddleware())
+		sessions.GET("", listSessionsHandler)
+		sessions.DELETE("/:id", revokeSessionHandler)
+		sessions.POST("/revoke-others", revokeOtherSessionsHandler)
+
+		tokens := auth.Group("/tokens", AuthMiddleware())
+		tokens.GET("", listTokensHandler)
+		tokens.POST("", createTokenHandler)
+		tokens.DELETE("/:id", revokeTokenHandler)
+
+		lockouts := auth.Group("/lockouts", AuthMiddleware(), RequirePrivileged(), RequirePermission(rbac.PermSettingsManage))
+		lockouts.GET("", listLockoutsHandler)
+		lockouts.DELETE("/:kind/:value", unlockHandler)
 	}
-	return t.Authenticate(0)
 }
 
 func trySudo(password string) bool {
@@ -81,20 +99,52 @@ func loginHandler(c *gin.Context) {
 		return
 	}
 
-	// 1. Authenticate with PAM
-	if err := pamAuth(req.Username, req.Password); err != nil {
-		logger.Warnf("❌ Authentication failed for user: %s", req.Username)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication failed"})
+	// 1. Refuse locked-out sources before spending a PAM conversation on them
+	if abortIfLockedOut(c, req.Username) {
+		return
+	}
+
+	// 2. Authenticate with PAM. Extra prompts (OTP modules, expired passwords)
+	// are relayed to the client and answered through /auth/login/respond.
+	conv := startPAMConversation(req.Username, req.Password)
+	if relayPAMEvent(c, conv) {
+		finishPasswordLogin(c, req.Username, conv.finalPassword())
+	}
+}
+
+// finishPasswordLogin continues a login once PAM has accepted the user.
+func finishPasswordLogin(c *gin.Context, username, password string) {
+	// 3. Check if user has sudo rights
+	privileged := trySudo(password)
+
+	// 4. Second factor: hand out a challenge instead of a session
+	if twoFactorEnabled(username) {
+		challenge := createPendingLogin(username, password, privileged)
+		logger.Infof("🔑 Password verified for user %s, waiting for second factor", username)
+		c.JSON(http.StatusOK, gin.H{"two_factor_required": true, "challenge": challenge})
 		return
 	}
 
-	// 2. Check if user has sudo rights
-	privileged := trySudo(req.Password)
+	// Privileged users without 2FA get an unprivileged session until they enrol
+	var extra gin.H
+	if privileged && config.GetServerConfig().Auth.Require2FAPrivileged {
+		logger.Warnf("User %s is privileged but has no 2FA enrolled, granting unprivileged session", username)
+		privileged = false
+		extra = gin.H{"two_factor_enrollment_required": true}
+	}
 
-	// 3. Create session (with privilege info)
+	recordLoginSuccess(username)
+	completeLogin(c, username, password, privileged, "", extra)
+}
+
+// completeLogin creates the session, starts its bridge and sets the session cookie.
+// A non-empty role replaces the roles derived from the user's groups.
+// extra fields are merged into the JSON response.
+func completeLogin(c *gin.Context, username, password string, privileged bool, role string, extra gin.H) {
+	// 1. Create session (with privilege info)
 	sessionID := uuid.New().String()
-	user := utils.User{ID: req.Username, Name: req.Username}
-	session.CreateSession(sessionID, user, sessionDuration, privileged)
+	user := utils.User{ID: username, Name: username}
+	session.CreateSession(sessionID, user, privileged)
 	sess := session.Get(sessionID)
 
 	if sess == nil {
@@ -102,8 +152,10 @@ func loginHandler(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "session creation failed"})
 		return
 	}
+	session.SetClient(sessionID, c.ClientIP(), c.Request.UserAgent())
+	applyRoles(sess, role)
 
-	// 4. Creating user specific config files
+	// 2. Creating user specific config files
 
 	logger.Infof("📦 Loading docker configuration...")
 	if err := config.LoadDockerConfig(); err != nil {
@@ -113,42 +165,82 @@ func loginHandler(c *gin.Context) {
 		logger.Errorf("❌ Failed to create docker apps directory: %v", err)
 	}
 
-	// 4. Start main socket for this session
-	if err := bridge.StartBridgeSocket(sess); err != nil {
-		logger.Errorf("Failed to start main socket: %v", err)
+	// 3. Start main socket and bridge process for this session
+	privileged, err := startSessionBridge(sess, password)
+	if err != nil {
 		session.DeleteSession(sessionID)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start session socket"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 5. Start the bridge process for this session
-	if err := bridge.StartBridge(sess, req.Password); err != nil {
-		if privileged {
-			logger.Warnf("Privileged bridge failed, falling back to unprivileged: %v", err)
-			privileged = false
-			if err2 := bridge.StartBridge(sess, req.Password); err2 != nil {
-				logger.Errorf("Unprivileged bridge also failed: %v", err2)
-				session.DeleteSession(sessionID)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start bridge"})
-				return
-			}
-		} else {
-			logger.Errorf("Bridge failed to start: %v", err)
-			session.DeleteSession(sessionID)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start bridge"})
-			return
+	// 4. Set session and CSRF cookies
+	maxAge := int(session.MaxLifetime().Seconds())
+	c.SetCookie("session_id", sessionID, maxAge, "/", "", secureCookies(c), true)
+	setCSRFCookie(c, sess.CSRFToken, maxAge)
+
+	// 5. Send response
+	resp := gin.H{"success": true, "privileged": privileged, "csrf_token": sess.CSRFToken}
+	for k, v := range extra {
+		resp[k] = v
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// applyRoles resolves the user's roles from their Unix groups, unless a role is given,
+// and records them on the session.
+// Must run before the bridge starts, since the bridge enforces the same permissions.
+func applyRoles(sess *session.Session, role string) {
+	roles, perms := rbac.Resolve(sess.User.ID)
+	if role != "" {
+		rolePerms, ok := rbac.RolePermissions(role)
+		if !ok {
+			logger.Warnf("Unknown role %q for user %s, granting no permissions", role, sess.User.ID)
 		}
+		roles, perms = []string{role}, rolePerms
 	}
+	session.SetRoles(sess.SessionID, roles, perms)
+	sess.Roles, sess.Permissions = roles, perms
+	logger.Infof("User %s has roles %v", sess.User.ID, roles)
+}
 
-	// 8. Set session cookie
-	env := os.Getenv("GO_ENV")
-	isHTTPS := c.Request.TLS != nil
-	secureCookie := env == "production" && isHTTPS
+// startSessionBridge starts the main socket and the bridge process for a session.
+// A privileged bridge that fails to start falls back to an unprivileged one;
+// the returned bool is the privilege the bridge actually runs with.
+func startSessionBridge(sess *session.Session, password string) (bool, error) {
+	privileged := sess.Privileged
 
-	c.SetCookie("session_id", sessionID, int(sessionDuration.Seconds()), "/", "", secureCookie, true)
+	if err := bridge.StartBridgeSocket(sess); err != nil {
+		logger.Errorf("Failed to start main socket: %v", err)
+		return false, errors.New("failed to start session socket")
+	}
 
-	// 9. Send response
-	c.JSON(http.StatusOK, gin.H{"success": true, "privileged": privileged})
+	if err := bridge.StartBridge(sess, password); err != nil {
+		if !privileged {
+			logger.Errorf("Bridge failed to start: %v", err)
+			_ = bridge.CleanupBridgeSocket(sess)
+			return false, errors.New("failed to start bridge")
+		}
+		logger.Warnf("Privileged bridge failed, falling back to unprivileged: %v", err)
+		privileged = false
+		session.SetPrivileged(sess.SessionID, false)
+		sess.Privileged = false
+		if err2 := bridge.StartBridge(sess, password); err2 != nil {
+			logger.Errorf("Unprivileged bridge also failed: %v", err2)
+			_ = bridge.CleanupBridgeSocket(sess)
+			return false, errors.New("failed to start bridge")
+		}
+	}
+	return privileged, nil
+}
+
+// terminateSession deletes the session, shuts its bridge down and removes its sockets.
+func terminateSession(s *session.Session) {
+	scheduleDrop(s.SessionID, time.Time{})
+	session.DeleteSession(s.SessionID)
+	if s.User.ID != "" {
+		_, _ = bridge.Call(context.Background(), s, "control", "shutdown", nil)
+		bridge.CleanupBridgeSocket(s)
+	}
 }
 
 func logoutHandler(c *gin.Context) {
@@ -161,23 +253,40 @@ func logoutHandler(c *gin.Context) {
 	s := session.Get(sessionID)
 	if s == nil {
 		logger.Debugf("[auth] No session found for ID: %s (already expired?)", sessionID)
-		c.SetCookie("session_id", "", -1, "/", "", false, true)
+		clearSessionCookies(c)
 		c.Status(http.StatusOK)
 		return
 	}
 
-	session.DeleteSession(sessionID)
-	if s.User.ID != "" {
-		bridge.CallWithSession(s, "control", "shutdown", nil)
-		bridge.CleanupFilebrowserContainer()
-		bridge.CleanupBridgeSocket(s)
-	}
-	c.SetCookie("session_id", "", -1, "/", "", false, true)
+	terminateSession(s)
+	bridge.CleanupFilebrowserContainer()
+	clearSessionCookies(c)
 	logger.Infof("👋 Logged out session: %s", sessionID)
 	c.Status(http.StatusOK)
 }
 
+// secureCookies reports whether cookies must be restricted to HTTPS.
+func secureCookies(c *gin.Context) bool {
+	return os.Getenv("GO_ENV") == "production" && c.Request.TLS != nil
+}
+
+// setCSRFCookie hands the session's CSRF token to the frontend, which echoes it in the X-CSRF-Token header.
+func setCSRFCookie(c *gin.Context, token string, maxAge int) {
+	c.SetCookie(csrfCookieName, token, maxAge, "/", "", secureCookies(c), false)
+}
+
+func clearSessionCookies(c *gin.Context) {
+	c.SetCookie("session_id", "", -1, "/", "", false, true)
+	c.SetCookie(csrfCookieName, "", -1, "/", "", false, false)
+}
+
 func meHandler(c *gin.Context) {
 	sess := c.MustGet("session").(*session.Session)
-	c.JSON(http.StatusOK, gin.H{"user": sess.User})
+	c.JSON(http.StatusOK, gin.H{
+		"user":           sess.User,
+		"privileged":     sess.Privileged,
+		"elevated_until": sess.ElevatedUntil,
+		"roles":          sess.Roles,
+		"permissions":    sess.Permissions,
+	})
 }
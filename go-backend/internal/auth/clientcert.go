@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"crypto/x509"
+	"go-backend/internal/config"
+	"go-backend/internal/logger"
+	"net/http"
+	"os/user"
+
+	"github.com/gin-gonic/gin"
+)
+
+// clientCertUser finds the configured mapping for a verified client certificate.
+// Subjects match either the common name or the full distinguished name.
+func clientCertUser(cert *x509.Certificate) (config.ClientCertUser, bool) {
+	for _, m := range config.GetServerConfig().TLS.ClientAuth.Users {
+		if m.Subject != "" && (m.Subject == cert.Subject.CommonName || m.Subject == cert.Subject.String()) {
+			return m, true
+		}
+	}
+	return config.ClientCertUser{}, false
+}
+
+// POST /auth/login/certificate logs in with the client certificate presented during the TLS handshake.
+// There is no password, so the session is never privileged; users can still elevate with /auth/elevate.
+func certificateLoginHandler(c *gin.Context) {
+	tlsState := c.Request.TLS
+	if tlsState == nil || len(tlsState.VerifiedChains) == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no verified client certificate"})
+		return
+	}
+	cert := tlsState.VerifiedChains[0][0]
+
+	mapping, ok := clientCertUser(cert)
+	if !ok {
+		logger.Warnf("❌ Client certificate %q from %s is not mapped to a user", cert.Subject.String(), c.ClientIP())
+		c.JSON(http.StatusForbidden, gin.H{"error": "certificate not authorized"})
+		return
+	}
+	if _, err := user.Lookup(mapping.User); err != nil {
+		logger.Errorf("Client certificate %q maps to unknown user %s: %v", cert.Subject.String(), mapping.User, err)
+		c.JSON(http.StatusForbidden, gin.H{"error": "certificate not authorized"})
+		return
+	}
+
+	logger.Infof("🔑 Client certificate %q accepted for user %s", cert.Subject.String(), mapping.User)
+	completeLogin(c, mapping.User, "", false, mapping.Role, gin.H{"auth_method": "certificate"})
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"go-backend/internal/bridge"
+	"go-backend/internal/config"
+	"go-backend/internal/logger"
+	"go-backend/internal/session"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// serializes bridge restarts, which are slow and must not interleave for one session
+	elevationMu sync.Mutex
+
+	dropTimersMu sync.Mutex
+	dropTimers   = make(map[string]*time.Timer)
+)
+
+// scheduleDrop drops the session back to unprivileged at until. A zero time cancels any pending drop.
+func scheduleDrop(sessionID string, until time.Time) {
+	dropTimersMu.Lock()
+	defer dropTimersMu.Unlock()
+
+	if t, ok := dropTimers[sessionID]; ok {
+		t.Stop()
+		delete(dropTimers, sessionID)
+	}
+	if until.IsZero() {
+		return
+	}
+	dropTimers[sessionID] = time.AfterFunc(time.Until(until), func() {
+		dropTimersMu.Lock()
+		delete(dropTimers, sessionID)
+		dropTimersMu.Unlock()
+
+		sess := session.Get(sessionID)
+		if sess == nil || !sess.Privileged || !sess.ElevatedUntil.Equal(until) {
+			return
+		}
+		logger.Infof("⏬ Elevation window ended for user %s (session: %s)", sess.User.ID, sessionID)
+		if err := dropPrivileges(sess); err != nil {
+			logger.Errorf("Failed to drop privileges for session %s: %v", sessionID, err)
+		}
+	})
+}
+
+// ResumeElevationTimers re-arms the auto-drop of elevated sessions restored from the session store.
+func ResumeElevationTimers() {
+	for _, id := range session.GetActiveSessionIDs() {
+		if sess := session.Get(id); sess != nil && sess.Privileged && !sess.ElevatedUntil.IsZero() {
+			scheduleDrop(id, sess.ElevatedUntil)
+		}
+	}
+}
+
+// dropPrivileges restarts the session's bridge unprivileged.
+func dropPrivileges(sess *session.Session) error {
+	elevationMu.Lock()
+	defer elevationMu.Unlock()
+
+	scheduleDrop(sess.SessionID, time.Time{})
+	session.SetPrivileged(sess.SessionID, false)
+	sess.Privileged = false
+	sess.ElevatedUntil = time.Time{}
+	return bridge.RestartBridge(sess, "")
+}
+
+// POST /auth/elevate re-verifies the password and restarts the session's bridge with sudo.
+func elevateHandler(c *gin.Context) {
+	sess := c.MustGet("session").(*session.Session)
+	var req struct {
+		Password string `json:"password"`
+		Code     string `json:"code"` // second factor, when enrolled
+	}
+	if err := c.BindJSON(&req); err != nil || req.Password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	if sess.Privileged {
+		c.JSON(http.StatusOK, gin.H{"privileged": true, "elevated_until": sess.ElevatedUntil})
+		return
+	}
+
+	username := sess.User.ID
+	if abortIfLockedOut(c, username) {
+		return
+	}
+	if err := pamAuth(username, req.Password); err != nil {
+		logger.Warnf("❌ Elevation failed for user %s: wrong password", username)
+		recordLoginFailure(c.ClientIP(), username)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication failed"})
+		return
+	}
+
+	cfg := config.GetServerConfig().Auth
+	if twoFactorEnabled(username) {
+		if !verifySecondFactor(username, req.Code, true) {
+			logger.Warnf("❌ Elevation failed for user %s: invalid second factor", username)
+			recordLoginFailure(c.ClientIP(), username)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid code", "two_factor_required": true})
+			return
+		}
+	} else if cfg.Require2FAPrivileged {
+		c.JSON(http.StatusForbidden, gin.H{"error": "two-factor enrolment required", "two_factor_enrollment_required": true})
+		return
+	}
+	recordLoginSuccess(username)
+
+	if !trySudo(req.Password) {
+		logger.Warnf("User %s requested elevation but has no sudo rights", username)
+		c.JSON(http.StatusForbidden, gin.H{"error": "user is not allowed to use sudo"})
+		return
+	}
+
+	elevationMu.Lock()
+	defer elevationMu.Unlock()
+
+	var until time.Time
+	if timeout := cfg.ElevationTimeout.Std(); timeout > 0 {
+		until = time.Now().Add(timeout)
+	}
+	session.SetElevated(sess.SessionID, until)
+	sess.Privileged = true
+	sess.ElevatedUntil = until
+
+	if err := bridge.RestartBridge(sess, req.Password); err != nil {
+		logger.Errorf("Privileged bridge failed for session %s, reverting: %v", sess.SessionID, err)
+		session.SetPrivileged(sess.SessionID, false)
+		sess.Privileged = false
+		if err := bridge.RestartBridge(sess, ""); err != nil {
+			logger.Errorf("Failed to restore unprivileged bridge for session %s: %v", sess.SessionID, err)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start privileged bridge"})
+		return
+	}
+
+	scheduleDrop(sess.SessionID, until)
+	logger.Infof("⏫ Session of user %s elevated (until: %v)", username, until)
+	c.JSON(http.StatusOK, gin.H{"privileged": true, "elevated_until": until})
+}
+
+// POST /auth/drop-privileges
+func dropPrivilegesHandler(c *gin.Context) {
+	sess := c.MustGet("session").(*session.Session)
+	if !sess.Privileged {
+		c.JSON(http.StatusOK, gin.H{"privileged": false})
+		return
+	}
+	if err := dropPrivileges(sess); err != nil {
+		logger.Errorf("Failed to drop privileges for session %s: %v", sess.SessionID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to restart bridge"})
+		return
+	}
+	logger.Infof("⏬ User %s dropped privileges (session: %s)", sess.User.ID, sess.SessionID)
+	c.JSON(http.StatusOK, gin.H{"privileged": false})
+}
@@ -0,0 +1,197 @@
+package auth
+
+import (
+	"fmt"
+	"go-backend/internal/config"
+	"go-backend/internal/logger"
+	"go-backend/internal/session"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// attemptRecord tracks failed logins for one source ("ip:<addr>" or "user:<name>").
+type attemptRecord struct {
+	Failures    []time.Time
+	LockedUntil time.Time
+	Lockouts    int // consecutive lockouts, drives the exponential back-off
+	LastFailure time.Time
+}
+
+type BlockedSource struct {
+	Kind        string    `json:"kind"` // "ip" or "user"
+	Value       string    `json:"value"`
+	Failures    int       `json:"failures"`
+	Lockouts    int       `json:"lockouts"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+var (
+	attemptsMu sync.Mutex
+	attempts   = make(map[string]*attemptRecord)
+)
+
+func attemptKeys(ip, username string) []string {
+	return []string{"ip:" + ip, "user:" + username}
+}
+
+func failureLimit(key string, cfg config.LockoutConfig) int {
+	if strings.HasPrefix(key, "ip:") {
+		return cfg.MaxFailuresPerIP
+	}
+	return cfg.MaxFailuresPerUser
+}
+
+// pruneLocked drops failures outside the window and forgets idle records. Caller holds attemptsMu.
+func pruneLocked(now time.Time, cfg config.LockoutConfig) {
+	for key, rec := range attempts {
+		kept := rec.Failures[:0]
+		for _, t := range rec.Failures {
+			if now.Sub(t) < cfg.Window.Std() {
+				kept = append(kept, t)
+			}
+		}
+		rec.Failures = kept
+		if len(kept) == 0 && rec.LockedUntil.Before(now) && now.Sub(rec.LastFailure) > cfg.MaxLockout.Std() {
+			delete(attempts, key)
+		}
+	}
+}
+
+// checkLoginAllowed reports whether the IP or username is currently locked out,
+// and how long the client has to wait. It never touches PAM.
+func checkLoginAllowed(ip, username string) (time.Duration, bool) {
+	cfg := config.GetServerConfig().Auth.Lockout
+	now := time.Now()
+
+	attemptsMu.Lock()
+	defer attemptsMu.Unlock()
+	pruneLocked(now, cfg)
+
+	var wait time.Duration
+	for _, key := range attemptKeys(ip, username) {
+		if rec, ok := attempts[key]; ok && rec.LockedUntil.After(now) {
+			wait = max(wait, rec.LockedUntil.Sub(now))
+		}
+	}
+	return wait, wait == 0
+}
+
+// recordLoginFailure counts a failed attempt and locks out sources that exceed their limit.
+func recordLoginFailure(ip, username string) {
+	cfg := config.GetServerConfig().Auth.Lockout
+	now := time.Now()
+
+	attemptsMu.Lock()
+	defer attemptsMu.Unlock()
+	pruneLocked(now, cfg)
+
+	for _, key := range attemptKeys(ip, username) {
+		rec, ok := attempts[key]
+		if !ok {
+			rec = &attemptRecord{}
+			attempts[key] = rec
+		}
+		// A long quiet period resets the back-off
+		if !rec.LastFailure.IsZero() && now.Sub(rec.LastFailure) > cfg.MaxLockout.Std() {
+			rec.Lockouts = 0
+		}
+		rec.Failures = append(rec.Failures, now)
+		rec.LastFailure = now
+
+		limit := failureLimit(key, cfg)
+		if limit > 0 && len(rec.Failures) >= limit {
+			lockout := cfg.MaxLockout.Std()
+			if rec.Lockouts < 20 {
+				if l := cfg.BaseLockout.Std() << rec.Lockouts; l > 0 && l < lockout {
+					lockout = l
+				}
+			}
+			rec.LockedUntil = now.Add(lockout)
+			rec.Lockouts++
+			rec.Failures = nil
+			logger.Warnf("🔒 Locked out %s for %s after repeated login failures", key, lockout)
+		}
+	}
+}
+
+// recordLoginSuccess clears the username's failures. The IP record is kept,
+// so one valid account can't be used to reset a password-spraying source.
+func recordLoginSuccess(username string) {
+	attemptsMu.Lock()
+	defer attemptsMu.Unlock()
+	delete(attempts, "user:"+username)
+}
+
+func listBlockedSources() []BlockedSource {
+	cfg := config.GetServerConfig().Auth.Lockout
+	now := time.Now()
+
+	attemptsMu.Lock()
+	defer attemptsMu.Unlock()
+	pruneLocked(now, cfg)
+
+	blocked := []BlockedSource{}
+	for key, rec := range attempts {
+		if !rec.LockedUntil.After(now) {
+			continue
+		}
+		kind, value, _ := strings.Cut(key, ":")
+		blocked = append(blocked, BlockedSource{
+			Kind:        kind,
+			Value:       value,
+			Failures:    len(rec.Failures),
+			Lockouts:    rec.Lockouts,
+			LockedUntil: rec.LockedUntil,
+		})
+	}
+	sort.Slice(blocked, func(i, j int) bool { return blocked[i].LockedUntil.After(blocked[j].LockedUntil) })
+	return blocked
+}
+
+// abortIfLockedOut answers 429 when the login source is locked out.
+func abortIfLockedOut(c *gin.Context, username string) bool {
+	wait, allowed := checkLoginAllowed(c.ClientIP(), username)
+	if allowed {
+		return false
+	}
+	seconds := int(wait.Round(time.Second).Seconds())
+	logger.Warnf("Blocked login attempt for user %s from %s (locked for %ds)", username, c.ClientIP(), seconds)
+	c.Header("Retry-After", fmt.Sprint(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed login attempts", "retry_after": seconds})
+	return true
+}
+
+// --- Admin handlers ---
+
+// GET /auth/lockouts
+func listLockoutsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, listBlockedSources())
+}
+
+// DELETE /auth/lockouts/:kind/:value
+func unlockHandler(c *gin.Context) {
+	sess := c.MustGet("session").(*session.Session)
+	kind, value := c.Param("kind"), c.Param("value")
+	if kind != "ip" && kind != "user" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be 'ip' or 'user'"})
+		return
+	}
+
+	key := kind + ":" + value
+	attemptsMu.Lock()
+	_, found := attempts[key]
+	delete(attempts, key)
+	attemptsMu.Unlock()
+
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no lockout for " + key})
+		return
+	}
+	logger.Infof("🔓 %s unlocked by %s", key, sess.User.ID)
+	c.JSON(http.StatusOK, gin.H{"unlocked": key})
+}
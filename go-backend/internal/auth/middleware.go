@@ -1,9 +1,13 @@
 package auth
 
 import (
+	"crypto/subtle"
 	"net/http"
+	"time"
 
+	"go-backend/internal/bridge"
 	"go-backend/internal/logger"
+	"go-backend/internal/rbac"
 	"go-backend/internal/session"
 	"go-backend/internal/utils"
 
@@ -19,8 +23,8 @@ func CorsMiddleware() gin.HandlerFunc {
 		if origin == devOrigin {
 			c.Header("Access-Control-Allow-Origin", origin)
 			c.Header("Access-Control-Allow-Credentials", "true")
-			c.Header("Access-Control-Allow-Headers", "Content-Type")
-			c.Header("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			c.Header("Access-Control-Allow-Headers", "Content-Type, "+csrfHeaderName)
+			c.Header("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
 
 			logger.Debugf("CORS allowed: %s %s", c.Request.Method, origin)
 		} else if origin != "" {
@@ -38,10 +42,10 @@ func CorsMiddleware() gin.HandlerFunc {
 
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		sess, valid := session.ValidateFromRequest(c.Request)
-		if !valid || sess == nil {
+		sess, status := authenticate(c)
+		if sess == nil {
 			logger.Warnf("⚠️  Unauthorized request or expired session")
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			c.AbortWithStatusJSON(status, gin.H{"error": http.StatusText(status)})
 			return
 		}
 		c.Set("session", sess)
@@ -51,12 +55,136 @@ func AuthMiddleware() gin.HandlerFunc {
 
 // Helper to validate session and handle unauthorized
 func GetSessionOrAbort(c *gin.Context) *session.Session {
-	sess, valid := session.ValidateFromRequest(c.Request)
-	if !valid || sess == nil {
+	sess, status := authenticate(c)
+	if sess == nil {
 		logger.Warnf("Unauthorized docker access")
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid session"})
+		c.JSON(status, gin.H{"error": "invalid session"})
 		c.Abort()
 		return nil
 	}
 	return sess
 }
+
+// authenticate resolves the request's session from a bearer API token or the session_id cookie.
+// On failure it returns nil and the HTTP status to answer with.
+func authenticate(c *gin.Context) (*session.Session, int) {
+	if v, ok := c.Get("session"); ok {
+		return v.(*session.Session), http.StatusOK
+	}
+
+	if raw, ok := bearerToken(c.Request); ok {
+		tok, err := lookupToken(raw)
+		if err != nil {
+			logger.Warnf("Rejected API token from %s: %v", c.ClientIP(), err)
+			return nil, http.StatusUnauthorized
+		}
+		if !tokenPathAllowed(c.Request.URL.Path) {
+			logger.Warnf("API token %q not allowed to %s %s", tok.Name, c.Request.Method, c.Request.URL.Path)
+			return nil, http.StatusForbidden
+		}
+		sess, err := sessionForToken(c, tok)
+		if err != nil {
+			logger.Errorf("Failed to open session for API token %q: %v", tok.Name, err)
+			return nil, http.StatusServiceUnavailable
+		}
+		session.Touch(sess.SessionID)
+		return sess, http.StatusOK
+	}
+
+	sess, valid := session.ValidateFromRequest(c.Request)
+	if !valid || sess == nil {
+		return nil, http.StatusUnauthorized
+	}
+	// Browsers attach the cookie to cross-site requests, so writes must prove same-origin
+	if !isSafeMethod(c.Request.Method) && !validCSRFToken(c.GetHeader(csrfHeaderName), sess.CSRFToken) {
+		logger.Warnf("Missing or invalid CSRF token on %s %s from %s (user: %s)", c.Request.Method, c.Request.URL.Path, c.ClientIP(), sess.User.ID)
+		return nil, http.StatusForbidden
+	}
+	// Sessions restored from before CSRF tokens existed got one the browser hasn't seen yet
+	if cookie, err := c.Cookie(csrfCookieName); err != nil || cookie != sess.CSRFToken {
+		maxAge := session.MaxLifetime()
+		if !sess.MaxExpiresAt.IsZero() {
+			maxAge = time.Until(sess.MaxExpiresAt)
+		}
+		setCSRFCookie(c, sess.CSRFToken, int(maxAge.Seconds()))
+	}
+	session.Touch(sess.SessionID)
+	return sess, http.StatusOK
+}
+
+const (
+	csrfCookieName = "csrf_token"
+	csrfHeaderName = "X-CSRF-Token"
+)
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+func validCSRFToken(got, want string) bool {
+	return want != "" && subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// RequirePrivileged rejects sessions without sudo rights. Must run after AuthMiddleware.
+func RequirePrivileged() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sess := c.MustGet("session").(*session.Session)
+		if !sess.Privileged {
+			logger.Warnf("Privileged route %s denied for user %s", c.FullPath(), sess.User.ID)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "administrator privileges required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePermission rejects sessions whose roles don't grant perm. Must run after AuthMiddleware.
+func RequirePermission(perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sess := c.MustGet("session").(*session.Session)
+		if !rbac.Has(sess.Permissions, perm) {
+			logger.Warnf("Route %s %s denied for user %s (missing %s)", c.Request.Method, c.FullPath(), sess.User.ID, perm)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "permission denied", "permission": perm})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireCapability answers 503 when the session's bridge reports the backend a route relies on
+// as missing or unusable, rather than letting the call fail. If no report can be had, the request
+// goes ahead and fails on its own. Must run after AuthMiddleware.
+func RequireCapability(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sess := c.MustGet("session").(*session.Session)
+		report, err := bridge.Capabilities(c.Request.Context(), sess, false)
+		if err != nil {
+			logger.Debugf("No capability report for session %s: %v", sess.SessionID, err)
+			c.Next()
+			return
+		}
+		if capability, ok := report.Capabilities[name]; ok && !capability.Usable() {
+			logger.Debugf("Route %s %s unavailable: %s is unusable (%s)", c.Request.Method, c.FullPath(), name, capability.Error)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+				"error":      name + " is not available: " + capability.Error,
+				"code":       bridge.CodeUnavailable,
+				"capability": name,
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireWritePermission is RequirePermission for state-changing requests only;
+// GET and HEAD requests pass through. Must run after AuthMiddleware.
+func RequireWritePermission(perm string) gin.HandlerFunc {
+	check := RequirePermission(perm)
+	return func(c *gin.Context) {
+		if isSafeMethod(c.Request.Method) {
+			c.Next()
+			return
+		}
+		check(c)
+	}
+}
@@ -0,0 +1,337 @@
+package auth
+
+/*
+#cgo LDFLAGS: -lpam
+#include <security/pam_appl.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"go-backend/internal/logger"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/msteinert/pam"
+)
+
+const (
+	pamService = "login"
+	// how long PAM waits for the client to answer a prompt
+	pamPromptTimeout = 2 * time.Minute
+	// how long a request waits for PAM to produce its next prompt or result
+	pamStepTimeout = 30 * time.Second
+)
+
+// PAMMessage is a PAM prompt or message relayed to the client.
+// Style is one of "echo_off", "echo_on", "info" or "error".
+type PAMMessage struct {
+	Style string `json:"style"`
+	Text  string `json:"text"`
+}
+
+// pamEvent is what the PAM goroutine hands to the HTTP side: either a prompt
+// that needs an answer, or the final result of the conversation.
+type pamEvent struct {
+	Messages       []PAMMessage
+	Prompt         *PAMMessage
+	PasswordChange bool
+	Done           bool
+	Err            error
+	ChangeFailed   bool // authentication succeeded but the forced password change did not
+}
+
+// pamConversation runs one PAM transaction in its own goroutine. The first hidden prompt
+// of the authentication phase is answered with the login password; every other prompt
+// is relayed to the client through /auth/login/respond.
+type pamConversation struct {
+	ID       string
+	Username string
+
+	password     string
+	passwordUsed bool
+	changing     bool   // inside the forced password change
+	newPassword  string // last hidden answer given while changing
+	pending      []PAMMessage
+
+	events  chan pamEvent
+	answers chan string
+	done    chan struct{}
+
+	abandonOnce sync.Once
+	abandoned   chan struct{} // closed once no request will read events any more
+
+	respondMu sync.Mutex // one answer at a time, so each request reads the event its answer caused
+}
+
+var (
+	conversationsMu sync.Mutex
+	conversations   = make(map[string]*pamConversation)
+)
+
+func pamStyleName(s pam.Style) string {
+	switch s {
+	case pam.PromptEchoOff:
+		return "echo_off"
+	case pam.PromptEchoOn:
+		return "echo_on"
+	case pam.ErrorMsg:
+		return "error"
+	case pam.TextInfo:
+		return "info"
+	}
+	return "unknown"
+}
+
+// authtokExpiredText is pam_strerror's text for PAM_NEW_AUTHTOK_REQD, in the process locale.
+var authtokExpiredText = C.GoString(C.pam_strerror(nil, C.PAM_NEW_AUTHTOK_REQD))
+
+// isAuthtokExpired reports whether AcctMgmt failed with PAM_NEW_AUTHTOK_REQD. The binding
+// returns the transaction as the error, which exposes the code only through pam_strerror.
+func isAuthtokExpired(err error) bool {
+	return err.Error() == authtokExpiredText
+}
+
+func startPAMConversation(username, password string) *pamConversation {
+	conv := &pamConversation{
+		ID:       uuid.New().String(),
+		Username: username,
+		password: password,
+		events:   make(chan pamEvent, 1),
+		answers:  make(chan string),
+		done:     make(chan struct{}),
+
+		abandoned: make(chan struct{}),
+	}
+	conversationsMu.Lock()
+	conversations[conv.ID] = conv
+	conversationsMu.Unlock()
+
+	go conv.run()
+	return conv
+}
+
+func (conv *pamConversation) run() {
+	defer close(conv.done)
+	defer func() {
+		conversationsMu.Lock()
+		delete(conversations, conv.ID)
+		conversationsMu.Unlock()
+	}()
+
+	t, err := pam.Start(pamService, conv.Username, conv)
+	if err != nil {
+		conv.emit(pamEvent{Done: true, Err: err})
+		return
+	}
+
+	err = t.Authenticate(0)
+	if err == nil {
+		err = t.AcctMgmt(0)
+		if err != nil && isAuthtokExpired(err) {
+			logger.Infof("🔑 Password of user %s has expired, requiring a change", conv.Username)
+			conv.changing = true
+			conv.pending = append(conv.pending, PAMMessage{Style: "info", Text: "Your password has expired and must be changed."})
+			if err = t.ChangeAuthTok(pam.ChangeExpiredAuthtok); err != nil {
+				conv.emit(pamEvent{Messages: conv.flush(), Done: true, Err: err, ChangeFailed: true})
+				return
+			}
+		}
+	}
+	conv.emit(pamEvent{Messages: conv.flush(), Done: true, Err: err})
+}
+
+// emit hands ev to the request waiting in nextEvent. It reports false if the conversation
+// was abandoned, in which case nobody will ever read it.
+func (conv *pamConversation) emit(ev pamEvent) bool {
+	select {
+	case conv.events <- ev:
+		return true
+	case <-conv.abandoned:
+		return false
+	}
+}
+
+// abandon gives up on the conversation: a prompt PAM is waiting on fails and the PAM
+// goroutine ends instead of blocking on an event nobody reads.
+func (conv *pamConversation) abandon() {
+	conv.abandonOnce.Do(func() {
+		close(conv.abandoned)
+		conversationsMu.Lock()
+		delete(conversations, conv.ID)
+		conversationsMu.Unlock()
+	})
+}
+
+func (conv *pamConversation) flush() []PAMMessage {
+	msgs := conv.pending
+	conv.pending = nil
+	return msgs
+}
+
+// RespondPAM implements pam.ConversationHandler. It runs on the PAM goroutine.
+func (conv *pamConversation) RespondPAM(style pam.Style, msg string) (string, error) {
+	switch style {
+	case pam.TextInfo, pam.ErrorMsg:
+		conv.pending = append(conv.pending, PAMMessage{Style: pamStyleName(style), Text: msg})
+		return "", nil
+	case pam.PromptEchoOff, pam.PromptEchoOn:
+	default:
+		return "", errors.New("unsupported PAM message style")
+	}
+
+	if style == pam.PromptEchoOff && !conv.passwordUsed && !conv.changing {
+		conv.passwordUsed = true
+		return conv.password, nil
+	}
+
+	if !conv.emit(pamEvent{
+		Messages:       conv.flush(),
+		Prompt:         &PAMMessage{Style: pamStyleName(style), Text: msg},
+		PasswordChange: conv.changing,
+	}) {
+		return "", errors.New("PAM conversation abandoned")
+	}
+	select {
+	case answer := <-conv.answers:
+		if conv.changing && style == pam.PromptEchoOff {
+			conv.newPassword = answer
+		}
+		return answer, nil
+	case <-conv.abandoned:
+		return "", errors.New("PAM conversation abandoned")
+	case <-time.After(pamPromptTimeout):
+		return "", errors.New("PAM prompt timed out")
+	}
+}
+
+// nextEvent waits for the conversation's next prompt or result.
+func (conv *pamConversation) nextEvent() (pamEvent, bool) {
+	select {
+	case ev := <-conv.events:
+		return ev, true
+	case <-time.After(pamStepTimeout):
+		return pamEvent{}, false
+	}
+}
+
+// finalPassword is the password the session should use, which changes with a forced password change.
+func (conv *pamConversation) finalPassword() string {
+	if conv.newPassword != "" {
+		return conv.newPassword
+	}
+	return conv.password
+}
+
+// pamAuth verifies a password without a client to relay prompts to (elevation).
+// Any prompt beyond the password fails the check rather than being answered blindly.
+func pamAuth(username, password string) error {
+	answered := false
+	t, err := pam.StartFunc(pamService, username, func(s pam.Style, msg string) (string, error) {
+		switch s {
+		case pam.PromptEchoOff:
+			if answered {
+				return "", errors.New("unexpected additional PAM prompt")
+			}
+			answered = true
+			return password, nil
+		case pam.TextInfo, pam.ErrorMsg:
+			return "", nil
+		}
+		return "", errors.New("interactive PAM prompt not supported")
+	})
+	if err != nil {
+		return err
+	}
+	if err := t.Authenticate(0); err != nil {
+		return err
+	}
+	return t.AcctMgmt(0)
+}
+
+// --- Handlers ---
+
+// relayPAMEvent answers the request with the conversation's next step and reports
+// whether the conversation finished successfully. If PAM doesn't get there in time,
+// the conversation is abandoned; the client has to log in again.
+func relayPAMEvent(c *gin.Context, conv *pamConversation) bool {
+	ev, ok := conv.nextEvent()
+	if !ok {
+		conv.abandon()
+		logger.Warnf("⏱️ PAM did not respond for user %s, abandoning the login", conv.Username)
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "authentication backend did not respond"})
+		return false
+	}
+
+	if ev.Prompt != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"conversation":    conv.ID,
+			"prompt":          ev.Prompt,
+			"messages":        ev.Messages,
+			"password_change": ev.PasswordChange,
+		})
+		return false
+	}
+
+	if ev.Err != nil {
+		if ev.ChangeFailed {
+			logger.Warnf("❌ Password change failed for user %s: %v", conv.Username, ev.Err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "password change failed", "messages": ev.Messages})
+			return false
+		}
+		logger.Warnf("❌ Authentication failed for user: %s", conv.Username)
+		recordLoginFailure(c.ClientIP(), conv.Username)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication failed", "messages": ev.Messages})
+		return false
+	}
+
+	if conv.newPassword != "" {
+		logger.Infof("🔑 User %s changed their expired password", conv.Username)
+	}
+	return true
+}
+
+// POST /auth/login/respond answers the prompt of a running PAM conversation.
+func loginRespondHandler(c *gin.Context) {
+	var req struct {
+		Conversation string `json:"conversation"`
+		Response     string `json:"response"`
+	}
+	if err := c.BindJSON(&req); err != nil || req.Conversation == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	conversationsMu.Lock()
+	conv, ok := conversations[req.Conversation]
+	conversationsMu.Unlock()
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "login conversation expired"})
+		return
+	}
+	if abortIfLockedOut(c, conv.Username) {
+		return
+	}
+
+	conv.respondMu.Lock()
+	defer conv.respondMu.Unlock()
+	select {
+	case conv.answers <- req.Response:
+	case <-conv.done:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "login conversation expired"})
+		return
+	case <-conv.abandoned:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "login conversation expired"})
+		return
+	case <-time.After(pamStepTimeout):
+		c.JSON(http.StatusConflict, gin.H{"error": "no prompt is waiting for an answer"})
+		return
+	}
+
+	if relayPAMEvent(c, conv) {
+		finishPasswordLogin(c, conv.Username, conv.finalPassword())
+	}
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"go-backend/internal/logger"
+	"go-backend/internal/session"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionInfo is the public view of a session. The session ID is the cookie
+// secret, so sessions are addressed by a hash of it instead.
+type SessionInfo struct {
+	ID           string    `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastActivity time.Time `json:"last_activity"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	ClientIP     string    `json:"client_ip"`
+	UserAgent    string    `json:"user_agent"`
+	Privileged   bool      `json:"privileged"`
+	APIToken     bool      `json:"api_token"`
+	Current      bool      `json:"current"`
+}
+
+func publicSessionID(sessionID string) string {
+	sum := sha256.Sum256([]byte(sessionID))
+	return hex.EncodeToString(sum[:8])
+}
+
+// GET /auth/sessions
+func listSessionsHandler(c *gin.Context) {
+	current := c.MustGet("session").(*session.Session)
+
+	list := []SessionInfo{}
+	for _, s := range session.ListByUser(current.User.ID) {
+		list = append(list, SessionInfo{
+			ID:           publicSessionID(s.SessionID),
+			CreatedAt:    s.CreatedAt,
+			LastActivity: s.LastActivity,
+			ExpiresAt:    s.ExpiresAt,
+			ClientIP:     s.ClientIP,
+			UserAgent:    s.UserAgent,
+			Privileged:   s.Privileged,
+			APIToken:     s.TokenID != "",
+			Current:      s.SessionID == current.SessionID,
+		})
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// DELETE /auth/sessions/:id
+func revokeSessionHandler(c *gin.Context) {
+	current := c.MustGet("session").(*session.Session)
+	id := c.Param("id")
+
+	for _, s := range session.ListByUser(current.User.ID) {
+		if publicSessionID(s.SessionID) != id {
+			continue
+		}
+		terminateSession(&s)
+		if s.SessionID == current.SessionID {
+			clearSessionCookies(c)
+		}
+		logger.Infof("Session %s of user %s revoked", id, current.User.ID)
+		c.JSON(http.StatusOK, gin.H{"revoked": id})
+		return
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+}
+
+// POST /auth/sessions/revoke-others
+func revokeOtherSessionsHandler(c *gin.Context) {
+	current := c.MustGet("session").(*session.Session)
+
+	revoked := []string{}
+	for _, s := range session.ListByUser(current.User.ID) {
+		if s.SessionID == current.SessionID {
+			continue
+		}
+		terminateSession(&s)
+		revoked = append(revoked, publicSessionID(s.SessionID))
+	}
+	logger.Infof("User %s revoked %d other sessions", current.User.ID, len(revoked))
+	c.JSON(http.StatusOK, gin.H{"revoked": revoked})
+}
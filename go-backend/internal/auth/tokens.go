@@ -0,0 +1,334 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"go-backend/internal/logger"
+	"go-backend/internal/rbac"
+	"go-backend/internal/session"
+	"go-backend/internal/utils"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	tokenStorePath = "/etc/linuxio/tokens.json"
+	tokenPrefix    = "lio_"
+)
+
+// Token scopes are the rbac permissions the routes check. A token session holds
+// the scopes its user also has, so a token never grants more than its owner.
+var validScopes = []string{
+	rbac.PermSystemView,
+	rbac.PermServicesManage,
+	rbac.PermNetworkManage,
+	rbac.PermDockerManage,
+	rbac.PermUpdatesManage,
+	rbac.PermPowerManage,
+}
+
+// legacyScopes maps the scope names of tokens created before scopes were permissions.
+// Those tokens could read everything, so each one also keeps system.view.
+var legacyScopes = map[string][]string{
+	"read-only": {rbac.PermSystemView},
+	"services":  {rbac.PermSystemView, rbac.PermServicesManage},
+	"docker":    {rbac.PermSystemView, rbac.PermDockerManage},
+	"power":     {rbac.PermSystemView, rbac.PermPowerManage},
+}
+
+// APIToken is a named, revocable bearer token. Only the SHA-256 hash of the secret is stored.
+type APIToken struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	User      string     `json:"user"`
+	Hash      string     `json:"hash,omitempty"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	LastUsed  *time.Time `json:"last_used,omitempty"`
+}
+
+var (
+	tokensMu sync.Mutex
+	tokens   map[string]APIToken
+
+	// serializes bridge start-up so concurrent first requests share one session
+	tokenSessionMu sync.Mutex
+)
+
+func loadTokens() map[string]APIToken {
+	if tokens != nil {
+		return tokens
+	}
+	tokens = make(map[string]APIToken)
+	data, err := os.ReadFile(tokenStorePath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Errorf("Failed to read token store: %v", err)
+		}
+		return tokens
+	}
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		logger.Errorf("Failed to parse token store: %v", err)
+	}
+	for id, tok := range tokens {
+		tok.Scopes = upgradeScopes(tok.Scopes)
+		tokens[id] = tok
+	}
+	return tokens
+}
+
+func saveTokens() error {
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(tokenStorePath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(tokenStorePath, data, 0600)
+}
+
+func hashTokenSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// bearerToken extracts the raw token from an "Authorization: Bearer lio_..." header.
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	raw, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || !strings.HasPrefix(raw, tokenPrefix) {
+		return "", false
+	}
+	return strings.TrimSpace(raw), true
+}
+
+// lookupToken validates a raw "lio_<id>_<secret>" token and returns its record.
+func lookupToken(raw string) (APIToken, error) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(raw, tokenPrefix), "_")
+	if !ok || id == "" || secret == "" {
+		return APIToken{}, errors.New("malformed token")
+	}
+
+	tokensMu.Lock()
+	defer tokensMu.Unlock()
+	records := loadTokens()
+	tok, found := records[id]
+	if !found || subtle.ConstantTimeCompare([]byte(tok.Hash), []byte(hashTokenSecret(secret))) != 1 {
+		return APIToken{}, errors.New("unknown token")
+	}
+	now := time.Now()
+	if tok.ExpiresAt != nil && tok.ExpiresAt.Before(now) {
+		go revokeTokenSession(tok.ID)
+		return APIToken{}, errors.New("token expired")
+	}
+
+	// Don't rewrite the store on every request
+	if tok.LastUsed == nil || now.Sub(*tok.LastUsed) > time.Minute {
+		tok.LastUsed = &now
+		records[id] = tok
+		if err := saveTokens(); err != nil {
+			logger.Warnf("Failed to record token use: %v", err)
+		}
+	}
+	return tok, nil
+}
+
+// sessionForToken returns the token's session, creating it and starting its bridge if needed.
+func sessionForToken(c *gin.Context, tok APIToken) (*session.Session, error) {
+	tokenSessionMu.Lock()
+	defer tokenSessionMu.Unlock()
+
+	if sess := session.FindByToken(tok.ID); sess != nil {
+		return sess, nil
+	}
+
+	sessionID := uuid.New().String()
+	user := utils.User{ID: tok.User, Name: tok.User}
+	// Token sessions are never privileged: there is no password to hand to sudo
+	session.CreateTokenSession(sessionID, tok.ID, user, false, tok.Scopes)
+	sess := session.Get(sessionID)
+	if sess == nil {
+		return nil, errors.New("session creation failed")
+	}
+	session.SetClient(sessionID, c.ClientIP(), c.Request.UserAgent())
+	applyRoles(sess, "")
+	perms := scopedPermissions(sess.Permissions, tok.Scopes)
+	session.SetRoles(sessionID, sess.Roles, perms)
+	sess.Permissions = perms
+
+	if _, err := startSessionBridge(sess, ""); err != nil {
+		session.DeleteSession(sessionID)
+		return nil, err
+	}
+	logger.Infof("🔑 Started bridge for API token %q (user: %s)", tok.Name, tok.User)
+	return session.Get(sessionID), nil
+}
+
+// upgradeScopes replaces legacy scope names with the permissions they stood for.
+func upgradeScopes(scopes []string) []string {
+	upgraded := []string{}
+	for _, scope := range scopes {
+		perms, ok := legacyScopes[scope]
+		if !ok {
+			perms = []string{scope}
+		}
+		for _, perm := range perms {
+			if !slices.Contains(upgraded, perm) {
+				upgraded = append(upgraded, perm)
+			}
+		}
+	}
+	return upgraded
+}
+
+// scopedPermissions returns the scopes the token's user actually has.
+func scopedPermissions(userPerms, scopes []string) []string {
+	perms := []string{}
+	for _, scope := range scopes {
+		if rbac.Has(userPerms, scope) {
+			perms = append(perms, scope)
+		}
+	}
+	return perms
+}
+
+// tokenPathAllowed reports whether a token may be used for the path at all. Tokens can't
+// open websockets or reach /auth/, where they could mint tokens or end sessions.
+func tokenPathAllowed(path string) bool {
+	return path != "/ws" && path != "/auth" && !strings.HasPrefix(path, "/auth/")
+}
+
+// revokeTokenSession shuts down the bridge opened by a token, if any.
+func revokeTokenSession(tokenID string) {
+	if sess := session.FindByToken(tokenID); sess != nil {
+		terminateSession(sess)
+	}
+}
+
+// --- Handlers ---
+
+// GET /auth/tokens
+func listTokensHandler(c *gin.Context) {
+	sess := c.MustGet("session").(*session.Session)
+
+	tokensMu.Lock()
+	defer tokensMu.Unlock()
+	list := []APIToken{}
+	for _, tok := range loadTokens() {
+		if tok.User == sess.User.ID {
+			tok.Hash = ""
+			list = append(list, tok)
+		}
+	}
+	slices.SortFunc(list, func(a, b APIToken) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	c.JSON(http.StatusOK, list)
+}
+
+// POST /auth/tokens
+func createTokenHandler(c *gin.Context) {
+	sess := c.MustGet("session").(*session.Session)
+	var req struct {
+		Name      string   `json:"name"`
+		Scopes    []string `json:"scopes"`
+		ExpiresIn string   `json:"expires_in"` // e.g. "720h", empty for no expiry
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Name) == "" || len(req.Scopes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name and scopes are required"})
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(validScopes, scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid scope: " + scope})
+			return
+		}
+	}
+
+	now := time.Now()
+	var expiresAt *time.Time
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid expires_in"})
+			return
+		}
+		t := now.Add(d)
+		expiresAt = &t
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		logger.Errorf("Failed to generate token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
+	secret := base64.RawURLEncoding.EncodeToString(buf)
+
+	tok := APIToken{
+		ID:        uuid.New().String(),
+		Name:      strings.TrimSpace(req.Name),
+		User:      sess.User.ID,
+		Hash:      hashTokenSecret(secret),
+		Scopes:    req.Scopes,
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+	}
+
+	tokensMu.Lock()
+	loadTokens()[tok.ID] = tok
+	err := saveTokens()
+	tokensMu.Unlock()
+	if err != nil {
+		logger.Errorf("Failed to save token store: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save token"})
+		return
+	}
+
+	logger.Infof("🔑 API token %q created for user %s (scopes: %v)", tok.Name, tok.User, tok.Scopes)
+	tok.Hash = ""
+	c.JSON(http.StatusCreated, gin.H{
+		"token": tokenPrefix + tok.ID + "_" + secret, // shown once, never stored
+		"info":  tok,
+	})
+}
+
+// DELETE /auth/tokens/:id
+func revokeTokenHandler(c *gin.Context) {
+	sess := c.MustGet("session").(*session.Session)
+	id := c.Param("id")
+
+	tokensMu.Lock()
+	records := loadTokens()
+	tok, found := records[id]
+	if !found || tok.User != sess.User.ID {
+		tokensMu.Unlock()
+		c.JSON(http.StatusNotFound, gin.H{"error": "token not found"})
+		return
+	}
+	delete(records, id)
+	err := saveTokens()
+	tokensMu.Unlock()
+	if err != nil {
+		logger.Errorf("Failed to save token store: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke token"})
+		return
+	}
+
+	revokeTokenSession(id)
+	logger.Infof("API token %q revoked by %s", tok.Name, sess.User.ID)
+	c.JSON(http.StatusOK, gin.H{"revoked": id})
+}
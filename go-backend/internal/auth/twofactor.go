@@ -0,0 +1,362 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"go-backend/internal/config"
+	"go-backend/internal/logger"
+	"go-backend/internal/session"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	totpStorePath        = "/etc/linuxio/totp.json"
+	pendingLoginTTL      = 5 * time.Minute
+	pendingLoginAttempts = 5
+	recoveryCodeCount    = 10
+)
+
+// TOTPRecord is the per-user second factor enrolment.
+// Recovery codes are stored as SHA-256 hashes and removed once used.
+type TOTPRecord struct {
+	Secret        string    `json:"secret"`
+	Enabled       bool      `json:"enabled"`
+	RecoveryCodes []string  `json:"recovery_codes"`
+	LastStep      int64     `json:"last_step"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// pendingLogin holds a password-verified login waiting for its second factor.
+// The password is kept only so the bridge can be started once the code is verified.
+type pendingLogin struct {
+	Username   string
+	Password   string
+	Privileged bool
+	ExpiresAt  time.Time
+	Attempts   int
+}
+
+var (
+	totpMu      sync.Mutex
+	totpRecords map[string]TOTPRecord
+
+	pendingMu     sync.Mutex
+	pendingLogins = make(map[string]*pendingLogin)
+)
+
+func loadTOTPRecords() map[string]TOTPRecord {
+	if totpRecords != nil {
+		return totpRecords
+	}
+	totpRecords = make(map[string]TOTPRecord)
+	data, err := os.ReadFile(totpStorePath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Errorf("Failed to read TOTP store: %v", err)
+		}
+		return totpRecords
+	}
+	if err := json.Unmarshal(data, &totpRecords); err != nil {
+		logger.Errorf("Failed to parse TOTP store: %v", err)
+	}
+	return totpRecords
+}
+
+func saveTOTPRecords() error {
+	data, err := json.MarshalIndent(totpRecords, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(totpStorePath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(totpStorePath, data, 0600)
+}
+
+func twoFactorEnabled(username string) bool {
+	totpMu.Lock()
+	defer totpMu.Unlock()
+	return loadTOTPRecords()[username].Enabled
+}
+
+// verifySecondFactor accepts either a current TOTP code or an unused recovery code.
+// With requireEnabled false it also accepts codes for a pending (unconfirmed) enrolment.
+func verifySecondFactor(username, code string, requireEnabled bool) bool {
+	totpMu.Lock()
+	defer totpMu.Unlock()
+
+	records := loadTOTPRecords()
+	rec, ok := records[username]
+	if !ok || (requireEnabled && !rec.Enabled) {
+		return false
+	}
+
+	if step, valid := validateTOTP(rec.Secret, code, rec.LastStep, time.Now()); valid {
+		rec.LastStep = step
+		records[username] = rec
+		if err := saveTOTPRecords(); err != nil {
+			logger.Errorf("Failed to save TOTP store: %v", err)
+		}
+		return true
+	}
+
+	if !rec.Enabled {
+		return false
+	}
+	hash := hashRecoveryCode(code)
+	for i, h := range rec.RecoveryCodes {
+		if h == hash {
+			rec.RecoveryCodes = append(rec.RecoveryCodes[:i], rec.RecoveryCodes[i+1:]...)
+			records[username] = rec
+			if err := saveTOTPRecords(); err != nil {
+				logger.Errorf("Failed to save TOTP store: %v", err)
+			}
+			logger.Warnf("Recovery code used by user %s (%d left)", username, len(rec.RecoveryCodes))
+			return true
+		}
+	}
+	return false
+}
+
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+func generateRecoveryCodes() ([]string, []string, error) {
+	const alphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		buf := make([]byte, 10)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		for i := range buf {
+			buf[i] = alphabet[int(buf[i])%len(alphabet)]
+		}
+		code := string(buf[:5]) + "-" + string(buf[5:])
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// --- Pending logins ---
+
+func createPendingLogin(username, password string, privileged bool) string {
+	id := uuid.New().String()
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+
+	now := time.Now()
+	for k, p := range pendingLogins {
+		if p.ExpiresAt.Before(now) {
+			delete(pendingLogins, k)
+		}
+	}
+	pendingLogins[id] = &pendingLogin{
+		Username:   username,
+		Password:   password,
+		Privileged: privileged,
+		ExpiresAt:  now.Add(pendingLoginTTL),
+	}
+	return id
+}
+
+// --- Handlers ---
+
+// POST /auth/login/2fa
+func loginTwoFactorHandler(c *gin.Context) {
+	var req struct {
+		Challenge string `json:"challenge"`
+		Code      string `json:"code"`
+	}
+	if err := c.BindJSON(&req); err != nil || req.Challenge == "" || req.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	pendingMu.Lock()
+	p, ok := pendingLogins[req.Challenge]
+	if ok && p.ExpiresAt.Before(time.Now()) {
+		delete(pendingLogins, req.Challenge)
+		ok = false
+	}
+	if !ok {
+		pendingMu.Unlock()
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "login challenge expired"})
+		return
+	}
+	p.Attempts++
+	pending := *p
+	if p.Attempts >= pendingLoginAttempts {
+		delete(pendingLogins, req.Challenge)
+	}
+	pendingMu.Unlock()
+
+	if abortIfLockedOut(c, pending.Username) {
+		return
+	}
+	if !verifySecondFactor(pending.Username, req.Code, true) {
+		logger.Warnf("❌ Invalid second factor for user: %s", pending.Username)
+		recordLoginFailure(c.ClientIP(), pending.Username)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid code"})
+		return
+	}
+
+	pendingMu.Lock()
+	delete(pendingLogins, req.Challenge)
+	pendingMu.Unlock()
+
+	logger.Infof("🔑 Second factor verified for user: %s", pending.Username)
+	recordLoginSuccess(pending.Username)
+	completeLogin(c, pending.Username, pending.Password, pending.Privileged, "", nil)
+}
+
+// GET /auth/2fa/status
+func twoFactorStatusHandler(c *gin.Context) {
+	sess := c.MustGet("session").(*session.Session)
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":  twoFactorEnabled(sess.User.ID),
+		"required": config.GetServerConfig().Auth.Require2FAPrivileged,
+	})
+}
+
+// POST /auth/2fa/setup starts (or restarts) an enrolment that must be confirmed with a code.
+func twoFactorSetupHandler(c *gin.Context) {
+	sess := c.MustGet("session").(*session.Session)
+
+	totpMu.Lock()
+	defer totpMu.Unlock()
+	records := loadTOTPRecords()
+	if records[sess.User.ID].Enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "two-factor authentication already enabled"})
+		return
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		logger.Errorf("Failed to generate TOTP secret: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate secret"})
+		return
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		logger.Errorf("Failed to generate recovery codes: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate recovery codes"})
+		return
+	}
+
+	records[sess.User.ID] = TOTPRecord{
+		Secret:        secret,
+		RecoveryCodes: hashes,
+		CreatedAt:     time.Now(),
+	}
+	if err := saveTOTPRecords(); err != nil {
+		logger.Errorf("Failed to save TOTP store: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save enrolment"})
+		return
+	}
+
+	logger.Infof("TOTP enrolment started for user %s", sess.User.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"secret":           secret,
+		"provisioning_uri": totpProvisioningURI(sess.User.ID, secret),
+		"recovery_codes":   codes,
+	})
+}
+
+// POST /auth/2fa/confirm
+func twoFactorConfirmHandler(c *gin.Context) {
+	sess := c.MustGet("session").(*session.Session)
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := c.BindJSON(&req); err != nil || req.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	if !verifySecondFactor(sess.User.ID, req.Code, false) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid code"})
+		return
+	}
+
+	totpMu.Lock()
+	defer totpMu.Unlock()
+	records := loadTOTPRecords()
+	rec := records[sess.User.ID]
+	rec.Enabled = true
+	records[sess.User.ID] = rec
+	if err := saveTOTPRecords(); err != nil {
+		logger.Errorf("Failed to save TOTP store: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save enrolment"})
+		return
+	}
+
+	logger.Infof("✅ Two-factor authentication enabled for user %s", sess.User.ID)
+	c.JSON(http.StatusOK, gin.H{"enabled": true})
+}
+
+// POST /auth/2fa/disable
+func twoFactorDisableHandler(c *gin.Context) {
+	sess := c.MustGet("session").(*session.Session)
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := c.BindJSON(&req); err != nil || req.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	if !verifySecondFactor(sess.User.ID, req.Code, true) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid code"})
+		return
+	}
+
+	totpMu.Lock()
+	defer totpMu.Unlock()
+	delete(loadTOTPRecords(), sess.User.ID)
+	if err := saveTOTPRecords(); err != nil {
+		logger.Errorf("Failed to save TOTP store: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save enrolment"})
+		return
+	}
+
+	logger.Warnf("Two-factor authentication disabled for user %s", sess.User.ID)
+	c.JSON(http.StatusOK, gin.H{"enabled": false})
+}
+
+// GET /auth/settings
+func getAuthSettingsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, config.GetServerConfig().Auth)
+}
+
+// POST /auth/settings
+func postAuthSettingsHandler(c *gin.Context) {
+	sess := c.MustGet("session").(*session.Session)
+	cfg := config.GetServerConfig()
+	if err := c.ShouldBindJSON(&cfg.Auth); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid settings"})
+		return
+	}
+	if err := config.SaveServerConfig(cfg); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save settings"})
+		return
+	}
+	logger.Infof("Auth settings updated by %s: %+v", sess.User.ID, cfg.Auth)
+	c.JSON(http.StatusOK, cfg.Auth)
+}
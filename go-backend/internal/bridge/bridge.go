@@ -13,6 +13,7 @@ import (
 	"os"
 	"os/exec"
 	"os/user"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -29,16 +30,11 @@ type BridgeProcess struct {
 	Cmd       *exec.Cmd
 	SessionID string
 	StartedAt time.Time
-}
-
-type BridgeResponse struct {
-	Status string          `json:"status"`
-	Output json.RawMessage `json:"output"`
-	Error  string          `json:"error"`
+	stopping  bool // exit was asked for; the supervisor doesn't restart it
 }
 
 type BridgeHealthRequest struct {
-	Type    string `json:"type"`    // e.g., "healthcheck" or "validate"
+	Type    string `json:"type"`    // "validate", or "events" to open an event stream
 	Session string `json:"session"` // sessionID
 }
 type BridgeHealthResponse struct {
@@ -56,13 +52,30 @@ var (
 	mainSocketListenersMu sync.Mutex
 )
 
+// runtimeDir replaces /run/user/<uid> as the home of every user's sockets when set.
+var runtimeDir string
+
+// SetRuntimeDir makes sockets live in dir instead of the users' /run/user directories.
+// It is meant for tests, which can't create sockets there; see bridgetest.
+func SetRuntimeDir(dir string) {
+	runtimeDir = dir
+}
+
+func userRuntimeDir(uid string) string {
+	if runtimeDir != "" {
+		return runtimeDir
+	}
+	return "/run/user/" + uid
+}
+
 // MainSocketPath returns the per-session main (healthcheck) socket path for the user.
+// Socket names use the bridge's random ID rather than the session ID.
 func MainSocketPath(sess *session.Session) string {
 	u, err := user.Lookup(sess.User.ID)
 	if err != nil {
 		panic(fmt.Sprintf("could not find user %s: %v", sess.User.ID, err))
 	}
-	return fmt.Sprintf("/run/user/%s/linuxio-main-%s.sock", u.Uid, sess.SessionID)
+	return fmt.Sprintf("%s/linuxio-main-%s.sock", userRuntimeDir(u.Uid), sess.BridgeID)
 }
 
 // BridgeSocketPath returns the per-session bridge command socket path for the user.
@@ -71,42 +84,55 @@ func BridgeSocketPath(sess *session.Session) string {
 	if err != nil {
 		panic(fmt.Sprintf("could not find user %s: %v", sess.User.ID, err))
 	}
-	return fmt.Sprintf("/run/user/%s/linuxio-bridge-%s.sock", u.Uid, sess.SessionID)
+	return fmt.Sprintf("%s/linuxio-bridge-%s.sock", userRuntimeDir(u.Uid), sess.BridgeID)
 }
 
-// Use everywhere for bridge actions: returns *raw* JSON response string (for HTTP handler to decode output as needed)
-func CallWithSession(sess *session.Session, reqType, command string, args []string) ([]byte, error) {
-	socketPath := BridgeSocketPath(sess)
-	return CallViaSocket(socketPath, reqType, command, args)
+// Call sends a command to the session's bridge and returns its output. Calls share one
+// persistent connection per session and may run concurrently; a broken connection is
+// re-dialled on the next call. When ctx ends, the bridge cancels the command.
+// Errors are *Error values carrying a code; HTTP handlers pass them to WriteError.
+func Call(ctx context.Context, sess *session.Session, reqType, command string, args []string) (json.RawMessage, error) {
+	return CallStream(ctx, sess, reqType, command, args, nil)
 }
 
-func CallViaSocket(socketPath, reqType, command string, args []string) ([]byte, error) {
-	req := map[string]any{
-		"type":    reqType,
-		"command": command,
-	}
-	if args != nil {
-		req["args"] = args
-	}
-	conn, err := net.DialTimeout("unix", socketPath, 2*time.Second)
+// CallStream is Call for long-running commands: progress updates the command reports before
+// its result are passed to onProgress. It runs on the connection's reader, so it must not block.
+func CallStream(ctx context.Context, sess *session.Session, reqType, command string, args []string, onProgress func(Progress)) (json.RawMessage, error) {
+	cl := clientFor(sess)
+	version, err := cl.ensureConnected()
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to bridge: %w", err)
+		return nil, err
 	}
-	defer conn.Close()
-	enc := json.NewEncoder(conn)
-	dec := json.NewDecoder(conn)
-	if err := enc.Encode(req); err != nil {
-		return nil, fmt.Errorf("failed to send request to bridge: %w", err)
+	// The bridge enforces the command's deadline; waiting a little longer lets its timeout error arrive
+	timeout := ConfiguredTimeouts().CommandTimeout(reqType, command) + deadlineGrace
+	resp, err := cl.roundTrip(ctx, Request{
+		Version: version,
+		Type:    reqType,
+		Command: command,
+		Args:    args,
+	}, timeout, onProgress)
+	if err != nil {
+		return nil, err
 	}
-	var resp BridgeResponse
-	if err := dec.Decode(&resp); err != nil {
-		return nil, fmt.Errorf("failed to decode response from bridge: %w", err)
+	if err := resp.Err(); err != nil {
+		return nil, err
 	}
-	b, err := json.Marshal(resp)
+	return resp.Output, nil
+}
+
+// CallInto is Call that decodes the output into v.
+func CallInto(ctx context.Context, sess *session.Session, reqType, command string, args []string, v any) error {
+	out, err := Call(ctx, sess, reqType, command, args)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal bridge response: %w", err)
+		return err
+	}
+	if len(out) == 0 || string(out) == "null" {
+		return nil
 	}
-	return b, nil
+	if err := json.Unmarshal(out, v); err != nil {
+		return Errorf(CodeInternal, "invalid output from bridge: %v", err)
+	}
+	return nil
 }
 
 // StartBridge starts the bridge process for a given session.
@@ -118,11 +144,18 @@ func StartBridge(sess *session.Session, sudoPassword string) error {
 		return errors.New("bridge already running for this session")
 	}
 
+	// Without a password, sudo would read the bootstrap line as one and the bridge would
+	// die after Start succeeded, looking like a crash instead of a failed start
+	if sess.Privileged && sudoPassword == "" {
+		return errors.New("a privileged bridge needs the sudo password")
+	}
+
 	var cmd *exec.Cmd
 	if sess.Privileged {
 		cmd = exec.Command("sudo", "-S", "env",
-			"LINUXIO_SESSION_ID="+sess.SessionID,
 			"LINUXIO_SESSION_USER="+sess.User.ID,
+			TimeoutsEnv+"="+ConfiguredTimeouts().String(),
+			HelperTimeoutEnv+"="+os.Getenv(HelperTimeoutEnv),
 			"GO_ENV="+os.Getenv("GO_ENV"),
 			"VERBOSE="+os.Getenv("VERBOSE"),
 			bridgeBinary,
@@ -130,8 +163,8 @@ func StartBridge(sess *session.Session, sudoPassword string) error {
 	} else {
 		cmd = exec.Command(bridgeBinary)
 		cmd.Env = append(os.Environ(),
-			"LINUXIO_SESSION_ID="+sess.SessionID,
 			"LINUXIO_SESSION_USER="+sess.User.ID,
+			TimeoutsEnv+"="+ConfiguredTimeouts().String(),
 			"GO_ENV="+os.Getenv("GO_ENV"),
 			"VERBOSE="+os.Getenv("VERBOSE"),
 		)
@@ -143,25 +176,39 @@ func StartBridge(sess *session.Session, sudoPassword string) error {
 	cmd.Stdout = io.MultiWriter(os.Stdout, &stdoutBuf)
 	cmd.Stderr = io.MultiWriter(os.Stderr, &stderrBuf)
 
-	if sess.Privileged && sudoPassword != "" {
-		stdin, err := cmd.StdinPipe()
-		if err != nil {
-			logger.Errorf("Failed to get stdin pipe: %v", err)
-			return err
-		}
-
-		// Convert password to a mutable byte slice
-		pwBytes := []byte(sudoPassword + "\n")
-		go func() {
-			defer stdin.Close()
-			_, _ = stdin.Write(pwBytes)
+	// The session ID and the bridge secret go through stdin, after the sudo password if any,
+	// so they never show up in the process list or environment
+	bootstrap, err := EncodeBootstrap(Bootstrap{
+		SessionID: sess.SessionID,
+		BridgeID:  sess.BridgeID,
+		Secret:    sess.BridgeSecret,
+		ServerUID: os.Getuid(),
+		ServerPID: os.Getpid(),
 
-			// Wipe the password bytes after use
-			for i := range pwBytes {
-				pwBytes[i] = 0
-			}
-		}()
+		Permissions: sess.Permissions,
+	})
+	if err != nil {
+		return err
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		logger.Errorf("Failed to get stdin pipe: %v", err)
+		return err
+	}
+	var input []byte
+	if sess.Privileged {
+		input = append(input, sudoPassword+"\n"...)
 	}
+	input = append(input, bootstrap...)
+	go func() {
+		defer stdin.Close()
+		_, _ = stdin.Write(input)
+
+		// Wipe the password and secret bytes after use
+		for i := range input {
+			input[i] = 0
+		}
+	}()
 
 	if err := cmd.Start(); err != nil {
 		logger.Errorf("Failed to start bridge for session %s: %v", sess.SessionID, err)
@@ -181,6 +228,7 @@ func StartBridge(sess *session.Session, sudoPassword string) error {
 		SessionID: sess.SessionID,
 		StartedAt: time.Now(),
 	}
+	bridgeStarted(sess, cmd.Process.Pid)
 
 	// Panic guard for process cleanup goroutine
 	go func(sessID string, cmd *exec.Cmd, stdoutBuf, stderrBuf *bytes.Buffer) {
@@ -193,8 +241,12 @@ func StartBridge(sess *session.Session, sudoPassword string) error {
 
 		err := cmd.Wait()
 		processesMu.Lock()
-		defer processesMu.Unlock()
-		delete(processes, sessID)
+		requested := false
+		if proc, ok := processes[sessID]; ok && proc.Cmd == cmd {
+			requested = proc.stopping
+			delete(processes, sessID)
+		}
+		processesMu.Unlock()
 
 		stdout := strings.TrimSpace(stdoutBuf.String())
 		stderr := strings.TrimSpace(stderrBuf.String())
@@ -211,13 +263,99 @@ func StartBridge(sess *session.Session, sudoPassword string) error {
 		} else {
 			logger.Infof("Bridge for session %s exited", sessID)
 		}
+		bridgeExited(sessID, err, requested)
 	}(sess.SessionID, cmd, &stdoutBuf, &stderrBuf)
 
 	return nil
 }
 
-// StartBridgeSocket starts a Unix socket server for the main process.
+// StopBridge asks the session's bridge to shut down and waits for it to exit.
+// A bridge that ignores the request is killed after the timeout.
+func StopBridge(sess *session.Session, timeout time.Duration) error {
+	processesMu.Lock()
+	if proc, ok := processes[sess.SessionID]; ok {
+		proc.stopping = true
+	}
+	processesMu.Unlock()
+
+	if _, err := Call(context.Background(), sess, "control", "shutdown", nil); err != nil {
+		logger.Warnf("Shutdown request to bridge for session %s failed: %v", sess.SessionID, err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if !bridgeRunning(sess) {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	processesMu.Lock()
+	proc, ok := processes[sess.SessionID]
+	processesMu.Unlock()
+	if !ok {
+		return fmt.Errorf("bridge for session %s did not shut down", sess.SessionID)
+	}
+	logger.Warnf("Bridge for session %s did not exit in %s, killing it", sess.SessionID, timeout)
+	if err := syscall.Kill(-proc.Cmd.Process.Pid, syscall.SIGKILL); err != nil {
+		return fmt.Errorf("failed to kill bridge: %w", err)
+	}
+	// Wait for the reaper goroutine to forget the process
+	for range 20 {
+		if !bridgeRunning(sess) {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("bridge for session %s did not exit after kill", sess.SessionID)
+}
+
+// bridgeRunning reports whether the session's bridge is still up. Bridges this server
+// didn't start (re-attached after a restart) are detected through their socket.
+func bridgeRunning(sess *session.Session) bool {
+	processesMu.Lock()
+	_, ok := processes[sess.SessionID]
+	processesMu.Unlock()
+	if ok {
+		return true
+	}
+	conn, err := net.DialTimeout("unix", BridgeSocketPath(sess), time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// RestartBridge replaces the session's bridge with a new one started with the session's
+// current privilege. The bridge removes the main socket on exit, so it is re-created too.
+func RestartBridge(sess *session.Session, sudoPassword string) error {
+	restartMu.Lock()
+	defer restartMu.Unlock()
+
+	if err := StopBridge(sess, 5*time.Second); err != nil {
+		return err
+	}
+	_ = CleanupBridgeSocket(sess)
+	if err := assignIdentity(sess, true); err != nil {
+		return err
+	}
+	if err := StartBridgeSocket(sess); err != nil {
+		return err
+	}
+	if err := StartBridge(sess, sudoPassword); err != nil {
+		_ = CleanupBridgeSocket(sess)
+		return err
+	}
+	return nil
+}
+
+// StartBridgeSocket starts a Unix socket server for the main process. A session without
+// a bridge identity gets one here.
 func StartBridgeSocket(sess *session.Session) error {
+	if err := assignIdentity(sess, false); err != nil {
+		return err
+	}
 	socketPath := MainSocketPath(sess)
 	_ = os.Remove(socketPath)
 	ln, err := net.Listen("unix", socketPath)
@@ -256,7 +394,7 @@ func StartBridgeSocket(sess *session.Session) error {
 						logger.Errorf("Panic in main socket handler: %v", r)
 					}
 				}()
-				handleBridgeRequest(conn)
+				handleBridgeRequest(conn, sess)
 			}()
 		}
 	}()
@@ -264,12 +402,54 @@ func StartBridgeSocket(sess *session.Session) error {
 	return nil
 }
 
-func handleBridgeRequest(conn net.Conn) {
+// ReattachSessions re-opens the main socket for every restored session whose bridge is still running,
+// so the bridge healthcheck keeps passing across a server restart.
+// Sessions whose bridge is gone are dropped, since the bridge can't be restarted without the password.
+// Re-attached bridges are supervised like the ones this server starts.
+func ReattachSessions() {
+	for _, id := range session.GetActiveSessionIDs() {
+		sess := session.Get(id)
+		if sess == nil {
+			continue
+		}
+
+		conn, err := net.DialTimeout("unix", BridgeSocketPath(sess), 2*time.Second)
+		if err != nil {
+			logger.Warnf("Bridge for restored session %s is not running, dropping session: %v", id, err)
+			session.DeleteSession(id)
+			_ = CleanupBridgeSocket(sess)
+			continue
+		}
+		conn.Close()
+
+		if err := StartBridgeSocket(sess); err != nil {
+			logger.Errorf("Failed to re-attach main socket for session %s: %v", id, err)
+			continue
+		}
+		watchAdopted(sess)
+		logger.Infof("Re-attached to running bridge for session %s (user: %s)", id, sess.User.ID)
+	}
+}
+
+// handleBridgeRequest answers the session's bridge on the main socket. Only the bridge may
+// ask: the peer must run as the session's user (or root, under sudo), descend from the
+// process this server started when there is one, and know the bridge secret.
+func handleBridgeRequest(conn net.Conn, sess *session.Session) {
 	defer conn.Close()
 	logger.Infof("Main socket accepted a connection")
+	if err := checkBridgePeer(conn, sess); err != nil {
+		logger.Warnf("❌ Rejected main socket connection for session %s: %v", sess.SessionID, err)
+		return
+	}
 	decoder := json.NewDecoder(conn)
 	encoder := json.NewEncoder(conn)
 
+	var hs Handshake
+	if err := decoder.Decode(&hs); err != nil || !SecretMatches(hs.Secret, sess.BridgeSecret) {
+		logger.Warnf("❌ Rejected main socket connection for session %s: bad handshake", sess.SessionID)
+		return
+	}
+
 	var req BridgeHealthRequest
 	if err := decoder.Decode(&req); err != nil {
 		logger.Warnf("Invalid JSON on main socket: %v", err)
@@ -277,6 +457,12 @@ func handleBridgeRequest(conn net.Conn) {
 		return
 	}
 
+	if req.Session != sess.SessionID {
+		logger.Warnf("Main socket of session %s got a request for another session", sess.SessionID)
+		_ = encoder.Encode(BridgeHealthResponse{Status: "invalid", Message: "wrong session"})
+		return
+	}
+
 	if req.Type == "validate" {
 		logger.Infof("Healthcheck received for session %s", req.Session)
 		if session.IsValid(req.Session) {
@@ -286,14 +472,41 @@ func handleBridgeRequest(conn net.Conn) {
 		}
 		return
 	}
+	if req.Type == "events" {
+		_ = encoder.Encode(BridgeHealthResponse{Status: "ok"})
+		receiveEvents(decoder, sess)
+		return
+	}
 	logger.Warnf("Unknown healthcheck request type: %s (session %s)", req.Type, req.Session)
 	_ = encoder.Encode(BridgeHealthResponse{Status: "error", Message: "unknown request type"})
 }
 
+func checkBridgePeer(conn net.Conn, sess *session.Session) error {
+	cred, err := PeerCred(conn)
+	if err != nil {
+		return err
+	}
+	u, err := user.Lookup(sess.User.ID)
+	if err != nil {
+		return err
+	}
+	if strconv.Itoa(int(cred.Uid)) != u.Uid && cred.Uid != 0 {
+		return fmt.Errorf("peer uid %d is not the session user's", cred.Uid)
+	}
+	processesMu.Lock()
+	proc, ours := processes[sess.SessionID]
+	processesMu.Unlock()
+	if ours && !isDescendant(int(cred.Pid), proc.Cmd.Process.Pid) {
+		return fmt.Errorf("peer pid %d is not the session's bridge", cred.Pid)
+	}
+	return nil
+}
+
 func CleanupBridgeSocket(sess *session.Session) error {
 	var firstErr error
 
 	logShutdownf("Starting CleanupBridgeSocket for session: %s", sess.SessionID)
+	closeClient(sess.SessionID)
 
 	mainSocketListenersMu.Lock()
 	ln, ok := mainSocketListeners[sess.SessionID]
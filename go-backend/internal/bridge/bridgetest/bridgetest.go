@@ -0,0 +1,276 @@
+// Package bridgetest runs an in-process fake of a session's bridge, so HTTP handlers that call
+// the bridge can be exercised without the bridge binary or the system services behind it.
+//
+// A fake answers on the session's bridge socket with the real protocol: the server's peer
+// credential check, the secret handshake and version negotiation all run as in production.
+// It also streams progress and honours cancellation. Responses are scripted per command and
+// every call is recorded:
+//
+//	func TestListServices(t *testing.T) {
+//		sess, b := bridgetest.Setup(t, rbac.PermSystemView)
+//		b.Respond("dbus", "ListServices", []string{"ssh.service"})
+//
+//		r := gin.New()
+//		services.RegisterServiceRoutes(r)
+//		rec := bridgetest.Do(r, sess, http.MethodGet, "/system/services/status", nil)
+//		if rec.Code != http.StatusOK {
+//			t.Fatalf("status = %d, want 200", rec.Code)
+//		}
+//	}
+package bridgetest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-backend/internal/bridge"
+	"go-backend/internal/session"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Handler scripts the answer to one command. progress sends a progress response first.
+type Handler func(ctx context.Context, args []string, progress func(bridge.Progress)) (any, error)
+
+// Call is a request the fake received, control requests aside.
+type Call struct {
+	Type    string
+	Command string
+	Args    []string
+}
+
+// Bridge is a fake bridge serving one session's bridge socket.
+type Bridge struct {
+	sess     *session.Session
+	listener net.Listener
+
+	mu       sync.Mutex
+	handlers map[string]Handler // "type/command" → handler
+	calls    []Call
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+// Start serves a fake bridge for sess, which must have a bridge identity (see NewSession).
+// Until scripted otherwise, every capability is reported usable and other commands fail
+// with not_found.
+func Start(sess *session.Session) (*Bridge, error) {
+	path := bridge.BridgeSocketPath(sess)
+	_ = os.Remove(path)
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	b := &Bridge{
+		sess:     sess,
+		listener: ln,
+		handlers: make(map[string]Handler),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	b.Respond("control", "capabilities", AllCapabilities())
+	b.wg.Add(1)
+	go b.serve()
+	return b, nil
+}
+
+// AllCapabilities is a report with every capability present and fully usable.
+func AllCapabilities() bridge.CapabilityReport {
+	names := []string{
+		bridge.CapSmartctl, bridge.CapNVMe, bridge.CapSensors, bridge.CapDocker,
+		bridge.CapSystemd, bridge.CapNetworkManager, bridge.CapPackageKit, bridge.CapWireGuard,
+	}
+	caps := make(map[string]bridge.Capability, len(names))
+	for _, name := range names {
+		caps[name] = bridge.Capability{Present: true, Access: bridge.AccessFull}
+	}
+	return bridge.CapabilityReport{ProbedAt: time.Now(), Privileged: true, Capabilities: caps}
+}
+
+// Handle scripts reqType/command with h, replacing any earlier script.
+func (b *Bridge) Handle(reqType, command string, h Handler) {
+	b.mu.Lock()
+	b.handlers[reqType+"/"+command] = h
+	b.mu.Unlock()
+}
+
+// Respond scripts reqType/command to succeed with output.
+func (b *Bridge) Respond(reqType, command string, output any) {
+	b.Handle(reqType, command, func(context.Context, []string, func(bridge.Progress)) (any, error) {
+		return output, nil
+	})
+}
+
+// Fail scripts reqType/command to fail with code.
+func (b *Bridge) Fail(reqType, command string, code bridge.ErrorCode, message string) {
+	b.Handle(reqType, command, func(context.Context, []string, func(bridge.Progress)) (any, error) {
+		return nil, bridge.Errorf(code, "%s", message)
+	})
+}
+
+// Calls returns the requests received so far, in order.
+func (b *Bridge) Calls() []Call {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Call(nil), b.calls...)
+}
+
+// Commands returns the requests received so far other than control requests, such as the
+// capability checks route middleware makes.
+func (b *Bridge) Commands() []Call {
+	var commands []Call
+	for _, call := range b.Calls() {
+		if call.Type != "control" {
+			commands = append(commands, call)
+		}
+	}
+	return commands
+}
+
+// CallsTo returns the received requests for reqType/command.
+func (b *Bridge) CallsTo(reqType, command string) []Call {
+	var matched []Call
+	for _, call := range b.Calls() {
+		if call.Type == reqType && call.Command == command {
+			matched = append(matched, call)
+		}
+	}
+	return matched
+}
+
+// Reset forgets the recorded calls.
+func (b *Bridge) Reset() {
+	b.mu.Lock()
+	b.calls = nil
+	b.mu.Unlock()
+}
+
+// Close stops the fake and drops the server's connection to it, so the next call fails
+// as it would with a dead bridge.
+func (b *Bridge) Close() error {
+	err := b.listener.Close()
+	b.mu.Lock()
+	for conn := range b.conns {
+		conn.Close()
+	}
+	b.mu.Unlock()
+	b.wg.Wait()
+	_ = bridge.CleanupBridgeSocket(b.sess)
+	return err
+}
+
+func (b *Bridge) serve() {
+	defer b.wg.Done()
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		b.mu.Lock()
+		b.conns[conn] = struct{}{}
+		b.mu.Unlock()
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			b.handleConnection(conn)
+			b.mu.Lock()
+			delete(b.conns, conn)
+			b.mu.Unlock()
+		}()
+	}
+}
+
+// handleConnection speaks the bridge protocol on one connection, answering requests
+// concurrently like the real bridge.
+func (b *Bridge) handleConnection(conn net.Conn) {
+	defer conn.Close()
+	dec := json.NewDecoder(conn)
+
+	var hs bridge.Handshake
+	if err := dec.Decode(&hs); err != nil || !bridge.SecretMatches(hs.Secret, b.sess.BridgeSecret) {
+		return
+	}
+
+	var (
+		writeMu sync.Mutex
+		enc     = json.NewEncoder(conn)
+		inMu    sync.Mutex
+		cancels = make(map[string]context.CancelFunc)
+		wg      sync.WaitGroup
+	)
+	send := func(resp bridge.Response) {
+		writeMu.Lock()
+		_ = enc.Encode(resp)
+		writeMu.Unlock()
+	}
+	defer func() {
+		inMu.Lock()
+		for _, cancel := range cancels {
+			cancel()
+		}
+		inMu.Unlock()
+		wg.Wait()
+	}()
+
+	for {
+		var req bridge.Request
+		if err := dec.Decode(&req); err != nil {
+			return
+		}
+		switch {
+		case req.Version < bridge.MinProtocolVersion || req.Version > bridge.ProtocolVersion:
+			// Like the real bridge, so a server sending unversioned requests fails here too
+			send(bridge.ErrorResponse(req, bridge.Errorf(bridge.CodeUnsupportedVersion,
+				"unsupported protocol version %d (supported %d-%d)", req.Version, bridge.MinProtocolVersion, bridge.ProtocolVersion)))
+			continue
+		case req.Type == "control" && req.Command == "hello":
+			send(bridge.OKResponse(req, bridge.HelloResult{Version: bridge.ProtocolVersion, MinVersion: bridge.MinProtocolVersion}))
+			continue
+		case req.Type == "control" && req.Command == "cancel":
+			if len(req.Args) == 1 {
+				inMu.Lock()
+				if cancel, ok := cancels[req.Args[0]]; ok {
+					cancel()
+				}
+				inMu.Unlock()
+			}
+			continue
+		}
+
+		b.mu.Lock()
+		b.calls = append(b.calls, Call{Type: req.Type, Command: req.Command, Args: req.Args})
+		h, ok := b.handlers[req.Type+"/"+req.Command]
+		b.mu.Unlock()
+		if !ok {
+			send(bridge.ErrorResponse(req, bridge.Errorf(bridge.CodeNotFound, "unknown command: %s %s", req.Type, req.Command)))
+			continue
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		inMu.Lock()
+		cancels[req.ID] = cancel
+		inMu.Unlock()
+		wg.Add(1)
+		go func(req bridge.Request) {
+			defer wg.Done()
+			defer func() {
+				cancel()
+				inMu.Lock()
+				delete(cancels, req.ID)
+				inMu.Unlock()
+			}()
+			progress := func(p bridge.Progress) {
+				if req.Version >= bridge.StreamingVersion {
+					send(bridge.ProgressResponse(req, p))
+				}
+			}
+			output, err := h(ctx, req.Args, progress)
+			if err != nil {
+				send(bridge.ErrorResponse(req, err))
+				return
+			}
+			send(bridge.OKResponse(req, output))
+		}(req)
+	}
+}
@@ -0,0 +1,79 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"go-backend/internal/session"
+	"time"
+)
+
+// Capability names reported by the bridge.
+const (
+	CapSmartctl       = "smartctl"
+	CapNVMe           = "nvme"
+	CapSensors        = "sensors"
+	CapDocker         = "docker"
+	CapSystemd        = "systemd"
+	CapNetworkManager = "networkmanager"
+	CapPackageKit     = "packagekit"
+	CapWireGuard      = "wireguard"
+)
+
+// Access levels of a capability at the bridge's current privilege.
+const (
+	AccessFull    = "full"    // everything works
+	AccessLimited = "limited" // reading works, changes need a privileged session
+	AccessNone    = "none"    // present, but unusable without a privileged session
+)
+
+// Capability is the probed state of one backend the bridge relies on.
+type Capability struct {
+	Present bool   `json:"present"`
+	Version string `json:"version,omitempty"`
+	Access  string `json:"access,omitempty"`
+	Error   string `json:"error,omitempty"` // why it is missing or unusable
+}
+
+// Usable reports whether features built on the capability can work at all.
+func (c Capability) Usable() bool {
+	return c.Present && c.Access != AccessNone
+}
+
+// CapabilityReport is the output of the control/capabilities command.
+type CapabilityReport struct {
+	ProbedAt     time.Time             `json:"probed_at"`
+	Privileged   bool                  `json:"privileged"`
+	Capabilities map[string]Capability `json:"capabilities"`
+}
+
+// Capabilities returns the capability report of the session's bridge. Reports are cached until
+// the bridge is restarted, since the new one may run with another privilege; refresh makes
+// the bridge probe again.
+func Capabilities(ctx context.Context, sess *session.Session, refresh bool) (CapabilityReport, error) {
+	cl := clientFor(sess)
+	if !refresh {
+		cl.mu.Lock()
+		cached := cl.caps
+		cl.mu.Unlock()
+		if cached != nil {
+			return *cached, nil
+		}
+	}
+
+	var args []string
+	if refresh {
+		args = []string{"true"}
+	}
+	out, err := Call(ctx, sess, "control", "capabilities", args)
+	if err != nil {
+		return CapabilityReport{}, err
+	}
+	var report CapabilityReport
+	if err := json.Unmarshal(out, &report); err != nil {
+		return CapabilityReport{}, Errorf(CodeInternal, "invalid capability report from bridge: %v", err)
+	}
+	cl.mu.Lock()
+	cl.caps = &report
+	cl.mu.Unlock()
+	return report, nil
+}
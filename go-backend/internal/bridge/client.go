@@ -0,0 +1,388 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-backend/internal/logger"
+	"go-backend/internal/session"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	dialTimeout = 2 * time.Second
+	// how much longer than the bridge's own deadline the server waits before giving up on a call
+	deadlineGrace = 5 * time.Second
+	// how often an idle connection is probed, and how long the probe may take
+	pingInterval = 30 * time.Second
+	pingTimeout  = 5 * time.Second
+)
+
+// ConnHealth describes the server's connection to a session's bridge.
+type ConnHealth struct {
+	Connected    bool      `json:"connected"`
+	Version      int       `json:"protocol_version,omitempty"`
+	InFlight     int       `json:"in_flight"`
+	ConnectedAt  time.Time `json:"connected_at,omitempty"`
+	LastResponse time.Time `json:"last_response,omitempty"`
+	Reconnects   int       `json:"reconnects"`
+	LastError    string    `json:"last_error,omitempty"`
+}
+
+// bridgeConn is the long-lived, multiplexed connection to one session's bridge.
+// Requests are written under writeMu and matched to responses by ID in readLoop.
+type bridgeConn struct {
+	sessionID  string
+	socketPath string
+	secret     string
+
+	mu      sync.Mutex
+	conn    net.Conn
+	enc     *json.Encoder
+	pending map[string]*pendingCall
+	closed  chan struct{} // closed when the current conn breaks
+	health  ConnHealth
+	stopped bool
+	caps    *CapabilityReport // cached by Capabilities
+
+	writeMu sync.Mutex
+	dialMu  sync.Mutex // one reconnect at a time
+}
+
+// pendingCall is an in-flight request waiting for its final response.
+type pendingCall struct {
+	done       chan Response
+	onProgress func(Progress)
+}
+
+var (
+	clientsMu sync.Mutex
+	clients   = make(map[string]*bridgeConn) // sessionID → connection
+)
+
+func clientFor(sess *session.Session) *bridgeConn {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	if cl, ok := clients[sess.SessionID]; ok {
+		return cl
+	}
+	// The caller's copy may predate a bridge restart, which changes socket and secret
+	if current := session.Get(sess.SessionID); current != nil {
+		sess = current
+	}
+	cl := &bridgeConn{
+		sessionID:  sess.SessionID,
+		socketPath: BridgeSocketPath(sess),
+		secret:     sess.BridgeSecret,
+		pending:    make(map[string]*pendingCall),
+	}
+	clients[sess.SessionID] = cl
+	return cl
+}
+
+// closeClient drops the session's connection; the next call dials a new one.
+func closeClient(sessionID string) {
+	clientsMu.Lock()
+	cl, ok := clients[sessionID]
+	delete(clients, sessionID)
+	clientsMu.Unlock()
+	if ok {
+		cl.stop()
+	}
+}
+
+// Health returns the state of the connection to the session's bridge.
+func Health(sess *session.Session) ConnHealth {
+	clientsMu.Lock()
+	cl, ok := clients[sess.SessionID]
+	clientsMu.Unlock()
+	if !ok {
+		return ConnHealth{}
+	}
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	h := cl.health
+	h.InFlight = len(cl.pending)
+	return h
+}
+
+// ensureConnected dials and negotiates if there is no live connection.
+func (cl *bridgeConn) ensureConnected() (int, error) {
+	cl.mu.Lock()
+	if cl.conn != nil {
+		v := cl.health.Version
+		cl.mu.Unlock()
+		return v, nil
+	}
+	cl.mu.Unlock()
+
+	cl.dialMu.Lock()
+	defer cl.dialMu.Unlock()
+
+	cl.mu.Lock()
+	if cl.conn != nil { // another caller reconnected meanwhile
+		v := cl.health.Version
+		cl.mu.Unlock()
+		return v, nil
+	}
+	if cl.stopped {
+		cl.mu.Unlock()
+		return 0, Errorf(CodeUnavailable, "bridge connection closed")
+	}
+	cl.mu.Unlock()
+
+	conn, err := net.DialTimeout("unix", cl.socketPath, dialTimeout)
+	if err != nil {
+		cl.setError(err)
+		return 0, Errorf(CodeUnavailable, "failed to connect to bridge: %v", err)
+	}
+	if err := cl.authenticate(conn); err != nil {
+		conn.Close()
+		cl.setError(err)
+		return 0, Errorf(CodeUnavailable, "bridge failed authentication: %v", err)
+	}
+	// The connection stays private until negotiated, so no caller sends on it without a version
+	version, dec, err := negotiate(conn)
+	if err != nil {
+		conn.Close()
+		cl.setError(err)
+		return 0, err
+	}
+
+	closed := make(chan struct{})
+	cl.mu.Lock()
+	cl.conn = conn
+	cl.enc = json.NewEncoder(conn)
+	cl.closed = closed
+	if !cl.health.ConnectedAt.IsZero() {
+		cl.health.Reconnects++
+	}
+	cl.health.Connected = true
+	cl.health.ConnectedAt = time.Now()
+	cl.health.LastResponse = time.Now()
+	cl.health.Version = version
+	cl.mu.Unlock()
+	go cl.readLoop(conn, dec)
+	go cl.pingLoop(conn, closed)
+
+	logger.Debugf("Connected to bridge for session %s (protocol v%d)", cl.sessionID, version)
+	return version, nil
+}
+
+// authenticate checks that the socket is served by the session's bridge, which runs as the
+// session's user or, under sudo, as root, and proves the server to it with the bridge secret.
+func (cl *bridgeConn) authenticate(conn net.Conn) error {
+	sess := session.Get(cl.sessionID)
+	if sess == nil {
+		return errors.New("session is gone")
+	}
+	if err := checkBridgePeer(conn, sess); err != nil {
+		return err
+	}
+	_ = conn.SetWriteDeadline(time.Now().Add(dialTimeout))
+	defer conn.SetWriteDeadline(time.Time{})
+	return json.NewEncoder(conn).Encode(Handshake{Secret: cl.secret})
+}
+
+// negotiate agrees on a protocol version with the bridge on a fresh connection, before
+// anything else uses it. It returns the decoder to keep reading the connection with.
+func negotiate(conn net.Conn) (int, *json.Decoder, error) {
+	_ = conn.SetDeadline(time.Now().Add(pingTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	req := Request{Version: ProtocolVersion, ID: uuid.NewString(), Type: "control", Command: "hello"}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return 0, nil, Errorf(CodeUnavailable, "failed to send hello to bridge: %v", err)
+	}
+	dec := json.NewDecoder(conn)
+	var resp Response
+	for {
+		var r Response
+		if err := dec.Decode(&r); err != nil {
+			return 0, nil, Errorf(CodeUnavailable, "bridge did not answer hello: %v", err)
+		}
+		if r.ID == req.ID && r.Status != "progress" {
+			resp = r
+			break
+		}
+	}
+	if err := resp.Err(); err != nil {
+		return 0, nil, Errorf(CodeUnsupportedVersion, "bridge did not negotiate a protocol version: %v", err)
+	}
+	var hello HelloResult
+	if err := json.Unmarshal(resp.Output, &hello); err != nil {
+		return 0, nil, Errorf(CodeUnsupportedVersion, "invalid hello from bridge: %v", err)
+	}
+
+	version := min(ProtocolVersion, hello.Version)
+	if version < MinProtocolVersion || version < hello.MinVersion {
+		return 0, nil, Errorf(CodeUnsupportedVersion, "no common protocol version (server %d-%d, bridge %d-%d)",
+			MinProtocolVersion, ProtocolVersion, hello.MinVersion, hello.Version)
+	}
+	return version, dec, nil
+}
+
+// roundTrip sends req on the current connection and waits for the final response with its ID.
+// Progress responses received meanwhile are passed to onProgress, which may be nil.
+// If ctx ends or the timeout passes first, the bridge is told to cancel the request.
+func (cl *bridgeConn) roundTrip(ctx context.Context, req Request, timeout time.Duration, onProgress func(Progress)) (Response, error) {
+	req.ID = uuid.NewString()
+	call := &pendingCall{done: make(chan Response, 1), onProgress: onProgress}
+
+	cl.mu.Lock()
+	conn, enc, closed := cl.conn, cl.enc, cl.closed
+	if conn == nil {
+		cl.mu.Unlock()
+		return Response{}, Errorf(CodeUnavailable, "not connected to bridge")
+	}
+	cl.pending[req.ID] = call
+	cl.mu.Unlock()
+	defer func() {
+		cl.mu.Lock()
+		delete(cl.pending, req.ID)
+		cl.mu.Unlock()
+	}()
+
+	// The timeout covers sending too, so a bridge that stops reading can't block the call
+	deadline := time.Now().Add(timeout)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := cl.write(conn, enc, req, deadline); err != nil {
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return Response{}, Errorf(CodeTimeout, "bridge did not accept %s %s within %s", req.Type, req.Command, timeout)
+		}
+		return Response{}, Errorf(CodeUnavailable, "failed to send request to bridge: %v", err)
+	}
+
+	select {
+	case resp := <-call.done:
+		return resp, nil
+	case <-closed:
+		return Response{}, Errorf(CodeUnavailable, "connection to bridge lost")
+	case <-ctx.Done():
+		cl.cancel(conn, enc, req)
+		return Response{}, Errorf(CodeCanceled, "%s %s canceled: %v", req.Type, req.Command, ctx.Err())
+	case <-timer.C:
+		cl.cancel(conn, enc, req)
+		return Response{}, Errorf(CodeTimeout, "bridge did not answer %s %s within %s", req.Type, req.Command, timeout)
+	}
+}
+
+// cancel asks the bridge to stop working on req. Bridges older than cancellation ignore it.
+func (cl *bridgeConn) cancel(conn net.Conn, enc *json.Encoder, req Request) {
+	if err := cl.write(conn, enc, CancelRequest(req.Version, req.ID), time.Now().Add(pingTimeout)); err != nil {
+		logger.Debugf("Failed to cancel bridge request %s: %v", req.ID, err)
+	}
+}
+
+// write encodes v on conn unless it takes past deadline. A failed write may have left
+// part of a message on the stream, so the connection is dropped.
+func (cl *bridgeConn) write(conn net.Conn, enc *json.Encoder, v any, deadline time.Time) error {
+	cl.writeMu.Lock()
+	_ = conn.SetWriteDeadline(deadline)
+	err := enc.Encode(v)
+	_ = conn.SetWriteDeadline(time.Time{})
+	cl.writeMu.Unlock()
+	if err != nil {
+		cl.drop(conn, err)
+	}
+	return err
+}
+
+// readLoop delivers responses to their callers until the connection breaks.
+func (cl *bridgeConn) readLoop(conn net.Conn, dec *json.Decoder) {
+	for {
+		var resp Response
+		if err := dec.Decode(&resp); err != nil {
+			cl.drop(conn, err)
+			return
+		}
+		cl.mu.Lock()
+		call, ok := cl.pending[resp.ID]
+		cl.health.LastResponse = time.Now()
+		cl.mu.Unlock()
+		if !ok {
+			logger.Warnf("Bridge for session %s answered unknown request %q", cl.sessionID, resp.ID)
+			continue
+		}
+		if resp.Status == "progress" {
+			if call.onProgress != nil && resp.Progress != nil {
+				call.onProgress(*resp.Progress)
+			}
+			continue
+		}
+		call.done <- resp
+	}
+}
+
+// drop forgets conn if it is still the current connection and fails its in-flight calls.
+func (cl *bridgeConn) drop(conn net.Conn, cause error) {
+	cl.mu.Lock()
+	if cl.conn != conn {
+		cl.mu.Unlock()
+		return
+	}
+	cl.conn = nil
+	cl.enc = nil
+	close(cl.closed)
+	cl.health.Connected = false
+	if cause != nil {
+		cl.health.LastError = cause.Error()
+	}
+	stopped := cl.stopped
+	cl.mu.Unlock()
+
+	conn.Close()
+	if !stopped {
+		logger.Warnf("Connection to bridge for session %s lost: %v", cl.sessionID, cause)
+	}
+}
+
+func (cl *bridgeConn) setError(err error) {
+	cl.mu.Lock()
+	cl.health.LastError = err.Error()
+	cl.mu.Unlock()
+}
+
+func (cl *bridgeConn) stop() {
+	cl.mu.Lock()
+	cl.stopped = true
+	conn := cl.conn
+	cl.mu.Unlock()
+	if conn != nil {
+		cl.drop(conn, nil)
+	}
+}
+
+// pingLoop probes conn while it is idle so a hung bridge is noticed before the next real call.
+func (cl *bridgeConn) pingLoop(conn net.Conn, closed chan struct{}) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ticker.C:
+		}
+		cl.mu.Lock()
+		version := cl.health.Version
+		idle := time.Since(cl.health.LastResponse) >= pingInterval
+		cl.mu.Unlock()
+		if version == 0 || !idle {
+			continue
+		}
+		if _, err := cl.roundTrip(context.Background(), Request{Version: version, Type: "control", Command: "hello"}, pingTimeout, nil); err != nil {
+			logger.Warnf("Bridge for session %s failed its ping: %v", cl.sessionID, err)
+			cl.drop(conn, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package bridge
+
+import (
+	"go-backend/internal/config"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TimeoutsEnv is the environment variable carrying the configured command deadlines to the bridge.
+const TimeoutsEnv = "LINUXIO_BRIDGE_TIMEOUTS"
+
+// HelperTimeoutEnv sets the deadline of module commands whose manifest declares none.
+const HelperTimeoutEnv = "BRIDGE_HELPER_TIMEOUT"
+
+// fallbackTimeout applies when neither the command, its group nor "default" is configured.
+const fallbackTimeout = 30 * time.Second
+
+// Timeouts maps "group" or "group.Command" to how long a command may run.
+type Timeouts map[string]time.Duration
+
+// moduleTimeouts holds the timeout each module command's manifest declares, zero if none,
+// keyed "type.command". Filled by RegisterModules.
+var moduleTimeouts = map[string]time.Duration{}
+
+// ConfiguredTimeouts returns the deadlines from serverConfig.yaml.
+func ConfiguredTimeouts() Timeouts {
+	t := Timeouts{}
+	for key, d := range config.GetServerConfig().Bridge.Timeouts {
+		t[key] = d.Std()
+	}
+	return t
+}
+
+// For returns the deadline of a command: its own entry, then its group's, then "default".
+func (t Timeouts) For(reqType, command string) time.Duration {
+	if d, ok := t[reqType+"."+command]; ok && d > 0 {
+		return d
+	}
+	if d, ok := t[reqType]; ok && d > 0 {
+		return d
+	}
+	if d, ok := t["default"]; ok && d > 0 {
+		return d
+	}
+	return fallbackTimeout
+}
+
+// CommandTimeout returns the deadline of a request. Module commands use the timeout from their
+// manifest unless t sets one for the command or its group; BRIDGE_HELPER_TIMEOUT, if set, still
+// applies to those that have neither. The server and the bridge both use it, so the server
+// never gives up on a command the bridge still lets run.
+func (t Timeouts) CommandTimeout(reqType, command string) time.Duration {
+	if manifest, ok := moduleTimeouts[reqType+"."+command]; ok {
+		_, own := t[reqType+"."+command]
+		_, group := t[reqType]
+		switch {
+		case own || group:
+		case manifest > 0:
+			return manifest
+		default:
+			if d, err := time.ParseDuration(os.Getenv(HelperTimeoutEnv)); err == nil && d > 0 {
+				return d
+			}
+		}
+	}
+	return t.For(reqType, command)
+}
+
+// String encodes the timeouts for TimeoutsEnv as "dbus=30s,dbus.InstallPackage=10m0s".
+func (t Timeouts) String() string {
+	parts := make([]string, 0, len(t))
+	for key, d := range t {
+		parts = append(parts, key+"="+d.String())
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// ParseTimeouts decodes the value of TimeoutsEnv, skipping malformed entries.
+func ParseTimeouts(s string) Timeouts {
+	t := Timeouts{}
+	for _, part := range strings.Split(s, ",") {
+		key, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || key == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(val); err == nil {
+			t[key] = d
+		}
+	}
+	return t
+}
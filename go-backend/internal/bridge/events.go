@@ -0,0 +1,66 @@
+package bridge
+
+import (
+	"encoding/json"
+	"go-backend/internal/logger"
+	"go-backend/internal/session"
+	"sync"
+	"time"
+)
+
+// Channels the bridge publishes events on. Websocket clients subscribe to them by name.
+const (
+	ChannelSystemdUnits = "systemd.units"       // unit active/sub state changes
+	ChannelDocker       = "docker.events"       // container, image, network and volume events
+	ChannelNetwork      = "network.state"       // NetworkManager and device state changes
+	ChannelPackageKit   = "packagekit.progress" // install progress and update list changes
+)
+
+// Event is a notification the bridge pushes to the server. After the handshake, the bridge
+// opens an event stream on the main socket with a BridgeHealthRequest of type "events" and
+// then writes one Event per JSON value.
+type Event struct {
+	Channel string          `json:"channel"`
+	Type    string          `json:"type"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Time    time.Time       `json:"time"`
+}
+
+// NewEvent builds an event with data encoded as JSON.
+func NewEvent(channel, eventType string, data any) (Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{Channel: channel, Type: eventType, Data: raw, Time: time.Now()}, nil
+}
+
+var (
+	eventHookMu sync.Mutex
+	eventHook   func(sessionID string, ev Event)
+)
+
+// SetEventHook registers fn to receive the events pushed by every session's bridge.
+func SetEventHook(fn func(sessionID string, ev Event)) {
+	eventHookMu.Lock()
+	eventHook = fn
+	eventHookMu.Unlock()
+}
+
+// receiveEvents hands the events of a bridge's event stream to the hook until the stream ends.
+func receiveEvents(dec *json.Decoder, sess *session.Session) {
+	logger.Infof("📡 Bridge for session %s opened its event stream", sess.SessionID)
+	for {
+		var ev Event
+		if err := dec.Decode(&ev); err != nil {
+			logger.Infof("Event stream of session %s closed: %v", sess.SessionID, err)
+			return
+		}
+		eventHookMu.Lock()
+		hook := eventHook
+		eventHookMu.Unlock()
+		if hook != nil {
+			hook(sess.SessionID, ev)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package bridge
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-backend/internal/session"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Bootstrap is written to the bridge's stdin at launch, after the sudo password if any.
+// It carries what must not be visible in the process's environment or command line.
+type Bootstrap struct {
+	SessionID string `json:"session_id"`
+	BridgeID  string `json:"bridge_id"`
+	Secret    string `json:"secret"`
+	ServerUID int    `json:"server_uid"`
+	ServerPID int    `json:"server_pid"`
+	// the session's rbac permissions, which the bridge checks every command against
+	Permissions []string `json:"permissions"`
+}
+
+// bootstrapMarker starts the bootstrap line, so the bridge never mistakes the sudo
+// password for it, whatever the password looks like.
+const bootstrapMarker = "LINUXIO-BOOTSTRAP "
+
+// EncodeBootstrap returns the stdin line carrying boot.
+func EncodeBootstrap(boot Bootstrap) ([]byte, error) {
+	data, err := json.Marshal(boot)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte(bootstrapMarker), data...), '\n'), nil
+}
+
+// Handshake is the first message on every connection to the bridge socket and to the
+// main socket. A connection whose secret doesn't match is closed without an answer.
+type Handshake struct {
+	Secret string `json:"secret"`
+}
+
+// ReadBootstrap reads the bootstrap from r. Lines without the marker are skipped: when sudo
+// doesn't ask for the password, the password line reaches the bridge instead.
+func ReadBootstrap(r io.Reader) (Bootstrap, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, ok := bytes.CutPrefix(scanner.Bytes(), []byte(bootstrapMarker))
+		if !ok {
+			continue
+		}
+		var boot Bootstrap
+		if err := json.Unmarshal(line, &boot); err != nil {
+			return Bootstrap{}, fmt.Errorf("invalid bootstrap: %w", err)
+		}
+		if boot.SessionID == "" || boot.BridgeID == "" || boot.Secret == "" {
+			return Bootstrap{}, errors.New("incomplete bootstrap")
+		}
+		return boot, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return Bootstrap{}, err
+	}
+	return Bootstrap{}, errors.New("no bootstrap on stdin")
+}
+
+// SecretMatches compares a handshake secret with the expected one in constant time.
+func SecretMatches(got, want string) bool {
+	return want != "" && subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// PeerCred returns the credentials of the process on the other end of a unix socket connection.
+func PeerCred(conn net.Conn) (*syscall.Ucred, error) {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil, errors.New("not a unix socket connection")
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		cred    *syscall.Ucred
+		credErr error
+	)
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return nil, err
+	}
+	return cred, credErr
+}
+
+// assignIdentity gives the session's bridge a socket name and secret. Existing ones are
+// kept unless renew is set, so a re-attached bridge keeps talking to the server.
+func assignIdentity(sess *session.Session, renew bool) error {
+	if sess.BridgeID != "" && sess.BridgeSecret != "" && !renew {
+		return nil
+	}
+	id, err := randomHex(16)
+	if err != nil {
+		return err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return err
+	}
+	session.SetBridgeIdentity(sess.SessionID, id, secret)
+	sess.BridgeID, sess.BridgeSecret = id, secret
+	return nil
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate bridge identity: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// isDescendant reports whether pid is ancestor or one of its descendants, such as the
+// bridge started under sudo.
+func isDescendant(pid, ancestor int) bool {
+	for range 8 {
+		if pid == ancestor {
+			return true
+		}
+		if pid <= 1 {
+			return false
+		}
+		stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		if err != nil {
+			return false
+		}
+		// The command name may contain spaces; the fields after it start behind the last ')'
+		end := strings.LastIndexByte(string(stat), ')')
+		fields := strings.Fields(string(stat[end+1:]))
+		if end < 0 || len(fields) < 2 {
+			return false
+		}
+		if pid, err = strconv.Atoi(fields[1]); err != nil {
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,252 @@
+package bridge
+
+import (
+	"errors"
+	"fmt"
+	"go-backend/internal/config"
+	"go-backend/internal/logger"
+	"go-backend/internal/rbac"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestFile is the manifest every module directory must contain.
+const ManifestFile = "manifest.yaml"
+
+// DefaultModulesDir holds one directory per helper module, overridable with LINUXIO_MODULES_DIR.
+const DefaultModulesDir = "/usr/lib/linuxio/modules"
+
+var validName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// Manifest describes a helper module. Its commands answer requests of type Type
+// (the module name by default) and run the executables named in Exec.
+type Manifest struct {
+	Name        string                   `yaml:"name" json:"name"`
+	Version     string                   `yaml:"version" json:"version"`
+	Description string                   `yaml:"description" json:"description"`
+	Type        string                   `yaml:"type" json:"type"`
+	Commands    map[string]ModuleCommand `yaml:"commands" json:"commands"`
+
+	Dir string `yaml:"-" json:"-"`
+}
+
+// ModuleCommand is one command of a module.
+type ModuleCommand struct {
+	Description string          `yaml:"description" json:"description"`
+	Exec        string          `yaml:"exec" json:"-"` // executable file in the module directory
+	Args        []ArgSpec       `yaml:"args" json:"args"`
+	Privileged  bool            `yaml:"privileged" json:"privileged"` // needs a privileged (sudo) bridge
+	Permission  string          `yaml:"permission" json:"permission"` // RBAC permission, admin-only when empty
+	Timeout     config.Duration `yaml:"timeout" json:"timeout,omitempty"`
+}
+
+// ModulesDir returns the modules directory.
+func ModulesDir() string {
+	if val := os.Getenv("LINUXIO_MODULES_DIR"); val != "" {
+		return val
+	}
+	return DefaultModulesDir
+}
+
+// LoadModules reads every module manifest under dir. Modules that are malformed or whose
+// files could be modified by someone other than root are skipped with an error each; if
+// dir itself or a directory above it could be, no module is loaded.
+func LoadModules(dir string) ([]*Manifest, []error) {
+	if err := checkTrustedDir(dir); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, []error{fmt.Errorf("modules directory: %w", err)}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	var (
+		modules []*Manifest
+		errs    []error
+		types   = map[string]string{}
+	)
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if !entry.IsDir() {
+			if entry.Type().IsRegular() && strings.Contains(entry.Name(), "_") {
+				errs = append(errs, fmt.Errorf("%s: helpers without a manifest are no longer run", path))
+			}
+			continue
+		}
+		m, err := loadManifest(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("module %s: %w", entry.Name(), err))
+			continue
+		}
+		if other, dup := types[m.Type]; dup {
+			errs = append(errs, fmt.Errorf("module %s: type %q already provided by module %s", m.Name, m.Type, other))
+			continue
+		}
+		types[m.Type] = m.Name
+		modules = append(modules, m)
+	}
+	sort.Slice(modules, func(i, j int) bool { return modules[i].Name < modules[j].Name })
+	return modules, errs
+}
+
+func loadManifest(dir string) (*Manifest, error) {
+	if err := checkTrusted(dir, true); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, ManifestFile)
+	if err := checkTrusted(path, false); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", ManifestFile, err)
+	}
+	m.Dir = dir
+
+	if !validName.MatchString(m.Name) {
+		return nil, fmt.Errorf("invalid module name %q", m.Name)
+	}
+	if m.Type == "" {
+		m.Type = m.Name
+	}
+	if !validName.MatchString(m.Type) {
+		return nil, fmt.Errorf("invalid type %q", m.Type)
+	}
+	if rbac.IsBuiltinType(m.Type) {
+		return nil, fmt.Errorf("type %q is reserved for built-in commands", m.Type)
+	}
+	if len(m.Commands) == 0 {
+		return nil, errors.New("no commands")
+	}
+
+	for name, cmd := range m.Commands {
+		if !validName.MatchString(name) {
+			return nil, fmt.Errorf("invalid command name %q", name)
+		}
+		if cmd.Exec == "" || strings.ContainsRune(cmd.Exec, '/') || cmd.Exec == "." || cmd.Exec == ".." {
+			return nil, fmt.Errorf("command %s: exec must name a file in the module directory", name)
+		}
+		if err := checkExecutable(filepath.Join(dir, cmd.Exec)); err != nil {
+			return nil, fmt.Errorf("command %s: %w", name, err)
+		}
+		if err := CompileArgs(cmd.Args); err != nil {
+			return nil, fmt.Errorf("command %s: %w", name, err)
+		}
+		m.Commands[name] = cmd
+	}
+	return &m, nil
+}
+
+// ExecPath returns the executable of one of the module's commands after checking again that
+// only root could have changed it, or the directories leading to it, since it was loaded.
+func (m *Manifest) ExecPath(command string) (string, error) {
+	cmd, ok := m.Commands[command]
+	if !ok {
+		return "", fmt.Errorf("module %s has no command %s", m.Name, command)
+	}
+	if err := checkTrustedDir(m.Dir); err != nil {
+		return "", err
+	}
+	path := filepath.Join(m.Dir, cmd.Exec)
+	if err := checkExecutable(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func checkExecutable(path string) error {
+	if err := checkTrusted(path, false); err != nil {
+		return err
+	}
+	if info, _ := os.Stat(path); info.Mode()&0111 == 0 {
+		return fmt.Errorf("%s is not executable", path)
+	}
+	return nil
+}
+
+// checkTrustedDir checks dir and every directory above it, so that no one but root can
+// rename or replace any of them. Symlinks above dir are resolved first.
+func checkTrustedDir(dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if err := checkTrusted(dir, true); err != nil {
+		return err
+	}
+	parent, err := filepath.EvalSymlinks(filepath.Dir(dir))
+	if err != nil {
+		return err
+	}
+	for {
+		if err := checkTrusted(parent, true); err != nil {
+			return err
+		}
+		up := filepath.Dir(parent)
+		if up == parent {
+			return nil
+		}
+		parent = up
+	}
+}
+
+// checkTrusted rejects files that anyone but root could have written: they must be owned
+// by root and not group- or world-writable. In development, files owned by the current
+// user are accepted too.
+func checkTrusted(path string, wantDir bool) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("%s is a symlink", path)
+	}
+	if wantDir != info.IsDir() || (!wantDir && !info.Mode().IsRegular()) {
+		return fmt.Errorf("%s has the wrong file type", path)
+	}
+	if info.Mode().Perm()&0022 != 0 {
+		return fmt.Errorf("%s is group- or world-writable", path)
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("cannot read owner of %s", path)
+	}
+	if st.Uid != 0 && !(os.Getenv("GO_ENV") == "development" && int(st.Uid) == os.Getuid()) {
+		return fmt.Errorf("%s is not owned by root", path)
+	}
+	return nil
+}
+
+// RegisterModules loads the modules in ModulesDir and registers the permission and timeout
+// of each command, so the server and the bridge check and time module commands alike.
+func RegisterModules() []*Manifest {
+	modules, errs := LoadModules(ModulesDir())
+	for _, err := range errs {
+		logger.Warnf("⚠️ Skipping bridge module: %v", err)
+	}
+	for _, m := range modules {
+		for name, cmd := range m.Commands {
+			perm := cmd.Permission
+			if perm == "" {
+				perm = rbac.PermAll
+			}
+			rbac.RegisterCommandPermission(m.Type, name, perm)
+			moduleTimeouts[m.Type+"."+name] = cmd.Timeout.Std()
+		}
+		logger.Infof("🧩 Loaded bridge module %s %s (%d commands)", m.Name, m.Version, len(m.Commands))
+	}
+	return modules
+}
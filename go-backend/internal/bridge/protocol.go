@@ -0,0 +1,161 @@
+package bridge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Protocol versions spoken on the bridge socket. A bridge accepts requests
+// for any version in [MinProtocolVersion, ProtocolVersion].
+//
+//	1: one response per request
+//	2: "progress" responses may precede the final one
+const (
+	ProtocolVersion    = 2
+	MinProtocolVersion = 1
+
+	// StreamingVersion is the first version that carries progress responses.
+	StreamingVersion = 2
+)
+
+// ErrorCode is a machine-readable bridge error class.
+type ErrorCode string
+
+const (
+	CodeNotFound           ErrorCode = "not_found"
+	CodePermissionDenied   ErrorCode = "permission_denied"
+	CodeInvalidArgs        ErrorCode = "invalid_args"
+	CodeTimeout            ErrorCode = "timeout"
+	CodeUnavailable        ErrorCode = "unavailable"
+	CodeUnsupportedVersion ErrorCode = "unsupported_version"
+	CodeCanceled           ErrorCode = "canceled"
+	CodeInternal           ErrorCode = "internal"
+)
+
+// Request is the envelope sent to the bridge and, unchanged, to external module helpers.
+//
+// A "control"/"cancel" request whose only argument is the ID of an earlier request on the
+// same connection cancels that request's context. It gets no response of its own.
+type Request struct {
+	Version int      `json:"version"`
+	ID      string   `json:"id"`
+	Type    string   `json:"type"`
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+}
+
+// Response is the envelope the bridge answers with. Status is "ok" or "error";
+// errors carry a Code. Helpers written before versioning may leave Version, ID and Code empty.
+// A request may get any number of "progress" responses before its final one.
+type Response struct {
+	Version  int             `json:"version"`
+	ID       string          `json:"id"`
+	Status   string          `json:"status"`
+	Output   json.RawMessage `json:"output,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Code     ErrorCode       `json:"code,omitempty"`
+	Progress *Progress       `json:"progress,omitempty"`
+}
+
+// Progress is a partial update from a long-running command.
+// Percent is -1 when the producer can't tell how far along it is.
+type Progress struct {
+	Percent int    `json:"percent"`
+	Status  string `json:"status,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// CancelRequest builds the request cancelling the in-flight request id.
+func CancelRequest(version int, id string) Request {
+	return Request{Version: version, Type: "control", Command: "cancel", Args: []string{id}}
+}
+
+// HelloResult is the output of the control/hello command used for version negotiation.
+type HelloResult struct {
+	Version    int `json:"version"`
+	MinVersion int `json:"min_version"`
+}
+
+// Error is a bridge error with its code. Handlers return it to choose the code
+// the client sees; any other error is reported as CodeInternal.
+type Error struct {
+	Code    ErrorCode
+	Message string
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Errorf returns an *Error with the given code.
+func Errorf(code ErrorCode, format string, args ...any) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// ErrorCodeOf returns the code of a bridge error, CodeInternal for other errors.
+func ErrorCodeOf(err error) ErrorCode {
+	var be *Error
+	if errors.As(err, &be) {
+		return be.Code
+	}
+	return CodeInternal
+}
+
+// OKResponse builds a successful response for req.
+func OKResponse(req Request, output any) Response {
+	raw, err := json.Marshal(output)
+	if err != nil {
+		return ErrorResponse(req, Errorf(CodeInternal, "failed to encode output: %v", err))
+	}
+	return Response{Version: req.Version, ID: req.ID, Status: "ok", Output: raw}
+}
+
+// ErrorResponse builds an error response for req from err.
+func ErrorResponse(req Request, err error) Response {
+	return Response{Version: req.Version, ID: req.ID, Status: "error", Error: err.Error(), Code: ErrorCodeOf(err)}
+}
+
+// ProgressResponse builds a progress update for req.
+func ProgressResponse(req Request, p Progress) Response {
+	return Response{Version: req.Version, ID: req.ID, Status: "progress", Progress: &p}
+}
+
+// Err returns the response's error as an *Error, or nil for a successful response.
+func (r Response) Err() error {
+	if r.Status == "ok" {
+		return nil
+	}
+	code := r.Code
+	if code == "" {
+		code = CodeInternal
+	}
+	return &Error{Code: code, Message: r.Error}
+}
+
+// HTTPStatus maps a bridge error to the HTTP status a handler should answer with.
+func HTTPStatus(err error) int {
+	switch ErrorCodeOf(err) {
+	case CodeNotFound:
+		return http.StatusNotFound
+	case CodePermissionDenied:
+		return http.StatusForbidden
+	case CodeInvalidArgs:
+		return http.StatusBadRequest
+	case CodeTimeout:
+		return http.StatusGatewayTimeout
+	case CodeCanceled:
+		return http.StatusRequestTimeout
+	case CodeUnavailable:
+		return http.StatusServiceUnavailable
+	case CodeUnsupportedVersion:
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+// WriteError answers an HTTP request with a bridge error and its code.
+func WriteError(c *gin.Context, err error) {
+	c.JSON(HTTPStatus(err), gin.H{"error": err.Error(), "code": ErrorCodeOf(err)})
+}
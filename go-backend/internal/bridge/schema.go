@@ -0,0 +1,116 @@
+package bridge
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+)
+
+// ArgSpec describes one positional argument of a bridge command.
+type ArgSpec struct {
+	Name     string   `yaml:"name" json:"name"`
+	Type     string   `yaml:"type" json:"type"` // "string" (default), "int", "bool" or "enum"
+	Required bool     `yaml:"required" json:"required"`
+	Pattern  string   `yaml:"pattern,omitempty" json:"pattern,omitempty"` // anchored regexp the value must match
+	Enum     []string `yaml:"enum,omitempty" json:"enum,omitempty"`
+	Variadic bool     `yaml:"variadic,omitempty" json:"variadic,omitempty"` // last argument only; takes the rest
+	Doc      string   `yaml:"description,omitempty" json:"description,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// CommandSchema describes a bridge command and its arguments to clients.
+type CommandSchema struct {
+	Type        string    `json:"type"`
+	Command     string    `json:"command"`
+	Description string    `json:"description,omitempty"`
+	Args        []ArgSpec `json:"args"`
+	Permission  string    `json:"permission,omitempty"` // empty: any session
+	Streaming   bool      `json:"streaming,omitempty"`  // reports progress before its result
+	Module      string    `json:"module,omitempty"`     // helper module providing the command
+}
+
+// CompileArgs checks a command's argument list and compiles its patterns.
+func CompileArgs(specs []ArgSpec) error {
+	optional := false
+	for i := range specs {
+		spec := &specs[i]
+		if spec.Name == "" {
+			return fmt.Errorf("argument %d has no name", i)
+		}
+		switch spec.Type {
+		case "":
+			spec.Type = "string"
+		case "string", "int", "bool":
+		case "enum":
+			if len(spec.Enum) == 0 {
+				return fmt.Errorf("argument %s: enum without values", spec.Name)
+			}
+		default:
+			return fmt.Errorf("argument %s: unknown type %q", spec.Name, spec.Type)
+		}
+		if spec.Variadic && i != len(specs)-1 {
+			return fmt.Errorf("argument %s: only the last argument can be variadic", spec.Name)
+		}
+		if spec.Required && optional {
+			return fmt.Errorf("argument %s: required argument after an optional one", spec.Name)
+		}
+		optional = optional || !spec.Required
+		if spec.Pattern != "" {
+			re, err := regexp.Compile("^(?:" + spec.Pattern + ")$")
+			if err != nil {
+				return fmt.Errorf("argument %s: %w", spec.Name, err)
+			}
+			spec.re = re
+		}
+	}
+	return nil
+}
+
+// ValidateArgs checks args against specs and returns a CodeInvalidArgs error on mismatch.
+func ValidateArgs(specs []ArgSpec, args []string) error {
+	variadic := len(specs) > 0 && specs[len(specs)-1].Variadic
+	if len(args) > len(specs) && !variadic {
+		return Errorf(CodeInvalidArgs, "too many arguments: got %d, want at most %d", len(args), len(specs))
+	}
+	for i, spec := range specs {
+		if i >= len(args) {
+			if spec.Required {
+				return Errorf(CodeInvalidArgs, "missing argument %s", spec.Name)
+			}
+			continue
+		}
+		values := args[i : i+1]
+		if spec.Variadic {
+			values = args[i:]
+		}
+		for _, v := range values {
+			if err := spec.check(v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (spec ArgSpec) check(v string) error {
+	switch spec.Type {
+	case "int":
+		if _, err := strconv.Atoi(v); err != nil {
+			return Errorf(CodeInvalidArgs, "argument %s must be an integer", spec.Name)
+		}
+	case "bool":
+		if _, err := strconv.ParseBool(v); err != nil {
+			return Errorf(CodeInvalidArgs, "argument %s must be true or false", spec.Name)
+		}
+	case "enum":
+		if !slices.Contains(spec.Enum, v) {
+			return Errorf(CodeInvalidArgs, "argument %s must be one of %v", spec.Name, spec.Enum)
+		}
+	}
+	if spec.re != nil && !spec.re.MatchString(v) {
+		return Errorf(CodeInvalidArgs, "argument %s has an invalid value", spec.Name)
+	}
+	return nil
+}
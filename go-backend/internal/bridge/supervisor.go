@@ -0,0 +1,247 @@
+package bridge
+
+import (
+	"errors"
+	"go-backend/internal/logger"
+	"go-backend/internal/session"
+	"sync"
+	"time"
+)
+
+const (
+	// first restart delay, doubled after every restart that doesn't stay up
+	restartBackoffMin = time.Second
+	restartBackoffMax = time.Minute
+	// a bridge that ran this long resets the back-off
+	stableAfter = time.Minute
+	// consecutive failed restarts before the supervisor gives up
+	maxRestarts = 5
+	// how often a re-attached bridge, which isn't our child, is checked for liveness
+	adoptedPollInterval = 10 * time.Second
+)
+
+// BridgeState is the lifecycle state of a session's bridge process.
+type BridgeState string
+
+const (
+	StateRunning    BridgeState = "running"
+	StateRestarting BridgeState = "restarting"
+	StateFailed     BridgeState = "failed" // gave up restarting; log in again or re-elevate
+	StateStopped    BridgeState = "stopped"
+)
+
+// Status describes a session's bridge process as seen by its supervisor.
+type Status struct {
+	State         BridgeState `json:"state"`
+	Privileged    bool        `json:"privileged"`
+	PID           int         `json:"pid,omitempty"`
+	StartedAt     time.Time   `json:"started_at,omitempty"`
+	Restarts      int         `json:"restarts"`
+	LastExit      string      `json:"last_exit,omitempty"`
+	LastExitAt    time.Time   `json:"last_exit_at,omitempty"`
+	NextRestartAt time.Time   `json:"next_restart_at,omitempty"`
+	// The password isn't kept, so a privileged bridge comes back unprivileged until the user re-elevates
+	ElevationLost bool `json:"elevation_lost,omitempty"`
+}
+
+type supervised struct {
+	status   Status
+	failures int // consecutive restarts that didn't stay up
+	timer    *time.Timer
+}
+
+var (
+	supervisorMu sync.Mutex
+	supervisors  = make(map[string]*supervised) // sessionID → supervision state
+
+	// serializes restarts of a bridge, whether requested or after a crash
+	restartMu sync.Mutex
+
+	statusHook func(sessionID string, st Status)
+)
+
+// SetStatusHook registers fn to be called whenever a session's bridge status changes.
+func SetStatusHook(fn func(sessionID string, st Status)) {
+	supervisorMu.Lock()
+	statusHook = fn
+	supervisorMu.Unlock()
+}
+
+// StatusOf returns the supervisor's view of the session's bridge.
+func StatusOf(sessionID string) Status {
+	supervisorMu.Lock()
+	defer supervisorMu.Unlock()
+	if s, ok := supervisors[sessionID]; ok {
+		return s.status
+	}
+	return Status{State: StateStopped}
+}
+
+// update changes a session's status under the lock and reports the result to the hook.
+func update(sessionID string, fn func(s *supervised)) {
+	supervisorMu.Lock()
+	s, ok := supervisors[sessionID]
+	if !ok {
+		s = &supervised{}
+		supervisors[sessionID] = s
+	}
+	fn(s)
+	st, hook := s.status, statusHook
+	supervisorMu.Unlock()
+	if hook != nil {
+		hook(sessionID, st)
+	}
+}
+
+func forget(sessionID string) {
+	supervisorMu.Lock()
+	defer supervisorMu.Unlock()
+	if s, ok := supervisors[sessionID]; ok && s.timer != nil {
+		s.timer.Stop()
+	}
+	delete(supervisors, sessionID)
+}
+
+// bridgeStarted records a newly started bridge process.
+func bridgeStarted(sess *session.Session, pid int) {
+	update(sess.SessionID, func(s *supervised) {
+		if s.timer != nil {
+			s.timer.Stop()
+			s.timer = nil
+		}
+		s.status.State = StateRunning
+		s.status.Privileged = sess.Privileged
+		s.status.PID = pid
+		s.status.StartedAt = time.Now()
+		s.status.NextRestartAt = time.Time{}
+		if sess.Privileged {
+			s.status.ElevationLost = false
+		}
+	})
+}
+
+// bridgeExited is called once a session's bridge has exited. Exits that were asked for,
+// or that follow the end of the session, are final; anything else schedules a restart.
+// A bridge exits cleanly by itself once its healthcheck finds the session gone or expired,
+// which an expired session still in the store until garbage collection must not hide.
+func bridgeExited(sessionID string, exitErr error, requested bool) {
+	reason := "exited"
+	if exitErr != nil {
+		reason = exitErr.Error()
+	}
+	if !session.IsValid(sessionID) {
+		if exitErr == nil {
+			logger.Infof("Bridge for ended session %s exited", sessionID)
+		}
+		forget(sessionID)
+		return
+	}
+	if requested {
+		update(sessionID, func(s *supervised) {
+			s.status.State = StateStopped
+			s.status.PID = 0
+		})
+		return
+	}
+
+	logger.Warnf("💥 Bridge for session %s died unexpectedly: %s", sessionID, reason)
+	update(sessionID, func(s *supervised) {
+		now := time.Now()
+		if !s.status.StartedAt.IsZero() && now.Sub(s.status.StartedAt) >= stableAfter {
+			s.failures = 0
+		}
+		s.status.PID = 0
+		s.status.LastExit = reason
+		s.status.LastExitAt = now
+		if s.failures >= maxRestarts {
+			s.status.State = StateFailed
+			logger.Errorf("❌ Bridge for session %s failed %d restarts in a row, giving up", sessionID, s.failures)
+			return
+		}
+		delay := min(restartBackoffMin<<s.failures, restartBackoffMax)
+		s.failures++
+		s.status.State = StateRestarting
+		s.status.NextRestartAt = now.Add(delay)
+		s.timer = time.AfterFunc(delay, func() { restartCrashed(sessionID) })
+		logger.Infof("🔁 Restarting bridge for session %s in %s (attempt %d/%d)", sessionID, delay, s.failures, maxRestarts)
+	})
+}
+
+// restartCrashed starts a new bridge for a session whose bridge died. A privileged
+// session is downgraded, since starting sudo again would need the password.
+func restartCrashed(sessionID string) {
+	restartMu.Lock()
+	defer restartMu.Unlock()
+
+	sess := session.Get(sessionID)
+	if sess == nil || !session.IsValid(sessionID) {
+		forget(sessionID)
+		return
+	}
+	if bridgeRunning(sess) {
+		// Restarted meanwhile, e.g. by an elevation
+		update(sessionID, func(s *supervised) {
+			s.status.State = StateRunning
+			s.status.NextRestartAt = time.Time{}
+		})
+		return
+	}
+
+	elevationLost := sess.Privileged
+	if elevationLost {
+		logger.Warnf("⏬ Restarting bridge for session %s unprivileged; the user must re-elevate", sessionID)
+		session.SetPrivileged(sessionID, false)
+		sess.Privileged = false
+		sess.ElevatedUntil = time.Time{}
+	}
+
+	_ = CleanupBridgeSocket(sess)
+	err := assignIdentity(sess, true)
+	if err == nil {
+		err = StartBridgeSocket(sess)
+	}
+	if err == nil {
+		err = StartBridge(sess, "")
+	}
+	if err != nil {
+		logger.Errorf("Failed to restart bridge for session %s: %v", sessionID, err)
+		_ = CleanupBridgeSocket(sess)
+		bridgeExited(sessionID, err, false)
+		return
+	}
+	update(sessionID, func(s *supervised) {
+		s.status.Restarts++
+		if elevationLost {
+			s.status.ElevationLost = true
+		}
+	})
+}
+
+// watchAdopted supervises a bridge re-attached after a server restart. It isn't our child,
+// so its exit is noticed by polling its socket, until this server starts a bridge of its own.
+func watchAdopted(sess *session.Session) {
+	update(sess.SessionID, func(s *supervised) {
+		s.status.State = StateRunning
+		s.status.Privileged = sess.Privileged
+	})
+	go func() {
+		ticker := time.NewTicker(adoptedPollInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			processesMu.Lock()
+			_, ours := processes[sess.SessionID]
+			processesMu.Unlock()
+			if ours {
+				return
+			}
+			if !session.IsValid(sess.SessionID) {
+				forget(sess.SessionID)
+				return
+			}
+			if !bridgeRunning(sess) {
+				bridgeExited(sess.SessionID, errors.New("re-attached bridge is gone"), false)
+				return
+			}
+		}
+	}()
+}
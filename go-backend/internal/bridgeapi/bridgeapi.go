@@ -0,0 +1,74 @@
+package bridgeapi
+
+import (
+	"go-backend/internal/auth"
+	"go-backend/internal/bridge"
+	"go-backend/internal/logger"
+	"go-backend/internal/rbac"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterBridgeRoutes exposes what the session's bridge can do.
+func RegisterBridgeRoutes(r *gin.Engine) {
+	group := r.Group("/bridge")
+	group.Use(auth.AuthMiddleware(), auth.RequirePermission(rbac.PermSystemView))
+
+	// The bridge process as seen by its supervisor, and the server's connection to it
+	group.GET("/status", func(c *gin.Context) {
+		sess := auth.GetSessionOrAbort(c)
+		if sess == nil {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"process":    bridge.StatusOf(sess.SessionID),
+			"connection": bridge.Health(sess),
+		})
+	})
+
+	// What the host supports; ?refresh=true probes again
+	group.GET("/capabilities", func(c *gin.Context) {
+		sess := auth.GetSessionOrAbort(c)
+		if sess == nil {
+			return
+		}
+		refresh := c.Query("refresh") == "true"
+		report, err := bridge.Capabilities(c.Request.Context(), sess, refresh)
+		if err != nil {
+			logger.Errorf("Fetching bridge capabilities failed: %v", err)
+			bridge.WriteError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	})
+
+	group.GET("/modules", func(c *gin.Context) {
+		sess := auth.GetSessionOrAbort(c)
+		if sess == nil {
+			return
+		}
+		output, err := bridge.Call(c.Request.Context(), sess, "modules", "list", nil)
+		if err != nil {
+			logger.Errorf("Listing bridge modules failed: %v", err)
+			bridge.WriteError(c, err)
+			return
+		}
+		c.Data(http.StatusOK, "application/json", output)
+	})
+
+	// Every command the bridge accepts, with its argument schema
+	group.GET("/schema", func(c *gin.Context) {
+		sess := auth.GetSessionOrAbort(c)
+		if sess == nil {
+			return
+		}
+		output, err := bridge.Call(c.Request.Context(), sess, "control", "schema", nil)
+		if err != nil {
+			logger.Errorf("Fetching bridge command schema failed: %v", err)
+			bridge.WriteError(c, err)
+			return
+		}
+		c.Data(http.StatusOK, "application/json", output)
+	})
+}
@@ -0,0 +1,333 @@
+package certificates
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"go-backend/internal/config"
+	"go-backend/internal/logger"
+	"math/big"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	managedDir      = "/etc/linuxio/tls"
+	managedCertPath = managedDir + "/cert.pem"
+	managedKeyPath  = managedDir + "/key.pem"
+
+	selfSignedValidity = 365 * 24 * time.Hour
+	// regenerate a self-signed certificate this long before it expires
+	renewBefore = 7 * 24 * time.Hour
+)
+
+// CertInfo describes the certificate currently served.
+type CertInfo struct {
+	Subject     string    `json:"subject"`
+	Issuer      string    `json:"issuer"`
+	DNSNames    []string  `json:"dns_names"`
+	IPAddresses []string  `json:"ip_addresses"`
+	NotBefore   time.Time `json:"not_before"`
+	NotAfter    time.Time `json:"not_after"`
+	Fingerprint string    `json:"fingerprint_sha256"`
+	SelfSigned  bool      `json:"self_signed"` // generated and managed by LinuxIO
+	CertFile    string    `json:"cert_file"`
+}
+
+var (
+	mu        sync.RWMutex
+	current   *tls.Certificate
+	info      CertInfo
+	clientCAs *x509.CertPool // nil unless client certificate login is configured
+)
+
+// Init loads the configured certificate, or the managed self-signed one, creating it if needed.
+func Init() error {
+	return Reload()
+}
+
+// Reload re-reads the certificate and the client CA bundle from disk. Clients connecting
+// afterwards get the new ones.
+func Reload() error {
+	tlsCfg := config.GetServerConfig().TLS
+
+	var (
+		cert    tls.Certificate
+		err     error
+		managed bool
+	)
+	switch {
+	case tlsCfg.CertFile != "" && tlsCfg.KeyFile != "":
+		cert, err = tls.LoadX509KeyPair(tlsCfg.CertFile, tlsCfg.KeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load certificate %s: %w", tlsCfg.CertFile, err)
+		}
+	case tlsCfg.CertFile != "" || tlsCfg.KeyFile != "":
+		return errors.New("tls.cert_file and tls.key_file must be set together")
+	default:
+		managed = true
+		cert, err = loadOrCreateSelfSigned()
+		if err != nil {
+			return err
+		}
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	cert.Leaf = leaf
+
+	certFile := tlsCfg.CertFile
+	if managed {
+		certFile = managedCertPath
+	}
+
+	mu.Lock()
+	current = &cert
+	info = describe(leaf, managed, certFile)
+	mu.Unlock()
+
+	logger.Infof("🔐 Serving TLS certificate %s (expires %s, sha256 %s)", leaf.Subject.CommonName, leaf.NotAfter.Format(time.RFC3339), info.Fingerprint)
+	if time.Now().After(leaf.NotAfter) {
+		logger.Warnf("⚠️  TLS certificate %s has expired", certFile)
+	}
+	return reloadClientCAs()
+}
+
+// reloadClientCAs re-reads the configured client CA bundle. A bundle that can't be loaded
+// leaves the current CAs in place.
+func reloadClientCAs() error {
+	caFile := config.GetServerConfig().TLS.ClientAuth.CAFile
+	var pool *x509.CertPool
+	if caFile != "" {
+		pemData, err := os.ReadFile(caFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA bundle: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pemData) {
+			return fmt.Errorf("no certificates found in client CA bundle %s", caFile)
+		}
+	}
+
+	mu.Lock()
+	enabled := clientCAs != nil
+	clientCAs = pool
+	mu.Unlock()
+
+	switch {
+	case pool != nil:
+		logger.Infof("🔐 Client certificate login enabled (CA bundle: %s)", caFile)
+	case enabled:
+		logger.Infof("🔐 Client certificate login disabled")
+	}
+	return nil
+}
+
+// ServerTLSConfig returns the TLS config for the HTTPS server. When a client CA bundle is
+// configured, clients may present a certificate, which must then chain to one of those CAs.
+// The bundle is looked up per connection, so a reload applies to new connections.
+func ServerTLSConfig() *tls.Config {
+	tlsCfg := &tls.Config{
+		GetCertificate: GetCertificate,
+		// Per-client configs are cloned from this one, so it must offer HTTP/2 itself
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+	tlsCfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		mu.RLock()
+		pool := clientCAs
+		mu.RUnlock()
+		if pool == nil {
+			return nil, nil // no client certificates asked for
+		}
+		withCAs := tlsCfg.Clone()
+		withCAs.GetConfigForClient = nil
+		withCAs.ClientCAs = pool
+		withCAs.ClientAuth = tls.VerifyClientCertIfGiven
+		return withCAs, nil
+	}
+	return tlsCfg
+}
+
+// GetCertificate is a tls.Config.GetCertificate callback serving the current certificate.
+func GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if current == nil {
+		return nil, errors.New("no TLS certificate loaded")
+	}
+	return current, nil
+}
+
+// Info returns details of the certificate currently served, and false if none is loaded.
+func Info() (CertInfo, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	return info, current != nil
+}
+
+// WatchSIGHUP reloads the server config and the certificate whenever the process receives SIGHUP.
+func WatchSIGHUP() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		for range ch {
+			logger.Infof("🔄 SIGHUP received, reloading TLS certificate and client CAs")
+			if err := config.LoadServerConfig(); err != nil {
+				logger.Errorf("❌ Failed to reload server config: %v", err)
+			}
+			if err := Reload(); err != nil {
+				logger.Errorf("❌ Failed to reload TLS certificate or client CAs, keeping the current ones: %v", err)
+			}
+		}
+	}()
+}
+
+func describe(leaf *x509.Certificate, managed bool, certFile string) CertInfo {
+	sum := sha256.Sum256(leaf.Raw)
+	ips := make([]string, 0, len(leaf.IPAddresses))
+	for _, ip := range leaf.IPAddresses {
+		ips = append(ips, ip.String())
+	}
+	return CertInfo{
+		Subject:     leaf.Subject.String(),
+		Issuer:      leaf.Issuer.String(),
+		DNSNames:    leaf.DNSNames,
+		IPAddresses: ips,
+		NotBefore:   leaf.NotBefore,
+		NotAfter:    leaf.NotAfter,
+		Fingerprint: strings.ToUpper(hex.EncodeToString(sum[:])),
+		SelfSigned:  managed,
+		CertFile:    certFile,
+	}
+}
+
+// loadOrCreateSelfSigned returns the managed certificate, regenerating it when missing,
+// unreadable or close to expiry.
+func loadOrCreateSelfSigned() (tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(managedCertPath, managedKeyPath)
+	if err == nil {
+		leaf, perr := x509.ParseCertificate(cert.Certificate[0])
+		if perr == nil && time.Until(leaf.NotAfter) > renewBefore {
+			return cert, nil
+		}
+		logger.Infof("Self-signed certificate is expired or about to expire, regenerating")
+	} else if !errors.Is(err, os.ErrNotExist) {
+		logger.Warnf("Failed to load self-signed certificate, regenerating: %v", err)
+	}
+
+	certPEM, keyPEM, err := generateSelfSigned()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := os.MkdirAll(managedDir, 0700); err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to create %s: %w", managedDir, err)
+	}
+	if err := writeFileAtomic(managedKeyPath, keyPEM, 0600); err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := writeFileAtomic(managedCertPath, certPEM, 0644); err != nil {
+		return tls.Certificate{}, err
+	}
+	logger.Infof("🔐 Generated self-signed certificate at %s", managedCertPath)
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// generateSelfSigned creates an ECDSA P-256 certificate valid for the hostname and the host's addresses.
+func generateSelfSigned() ([]byte, []byte, error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "localhost"
+	}
+	dnsNames := []string{hostname}
+	if hostname != "localhost" {
+		dnsNames = append(dnsNames, "localhost")
+	}
+
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: hostname, Organization: []string{"LinuxIO"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              dnsNames,
+		IPAddresses:           hostIPs(),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// hostIPs returns loopback plus every address configured on the host's interfaces.
+func hostIPs() []net.IP {
+	ips := []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		logger.Warnf("Failed to list interface addresses for certificate SANs: %v", err)
+		return ips
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		ips = append(ips, ipNet.IP)
+	}
+	return ips
+}
+
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
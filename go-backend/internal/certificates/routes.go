@@ -0,0 +1,25 @@
+package certificates
+
+import (
+	"go-backend/internal/auth"
+	"go-backend/internal/rbac"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterCertificateRoutes(router *gin.Engine) {
+	certificate := router.Group("/certificate", auth.AuthMiddleware(), auth.RequirePermission(rbac.PermSystemView))
+	{
+		certificate.GET("", getCertificateInfo)
+	}
+}
+
+func getCertificateInfo(c *gin.Context) {
+	certInfo, ok := Info()
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "TLS is not enabled"})
+		return
+	}
+	c.JSON(http.StatusOK, certInfo)
+}
@@ -0,0 +1,241 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go-backend/internal/logger"
+
+	"gopkg.in/yaml.v3"
+)
+
+const serverConfigPath = "/etc/linuxio/serverConfig.yaml"
+
+type ServerConfig struct {
+	Auth    AuthConfig    `yaml:"auth" json:"auth"`
+	Session SessionConfig `yaml:"session" json:"session"`
+	RBAC    RBACConfig    `yaml:"rbac" json:"rbac"`
+	TLS     TLSConfig     `yaml:"tls" json:"tls"`
+	Bridge  BridgeConfig  `yaml:"bridge" json:"bridge"`
+	Metrics MetricsConfig `yaml:"metrics" json:"metrics"`
+}
+
+// BridgeConfig limits how long bridge commands may run. Timeouts is keyed by command group
+// ("dbus", "docker", "system", a module helper's type) or by "group.Command" for a single
+// command; "default" covers everything else.
+type BridgeConfig struct {
+	Timeouts map[string]Duration `yaml:"timeouts" json:"timeouts"`
+}
+
+// MetricsConfig sets how often the websocket metrics channels ("system.cpu", "docker.stats", ...)
+// are sampled while someone is subscribed. "default" covers channels not listed.
+type MetricsConfig struct {
+	Intervals map[string]Duration `yaml:"intervals" json:"intervals"`
+}
+
+// Interval returns the sampling interval of channel.
+func (m MetricsConfig) Interval(channel string) time.Duration {
+	if d, ok := m.Intervals[channel]; ok && d > 0 {
+		return d.Std()
+	}
+	if d, ok := m.Intervals["default"]; ok && d > 0 {
+		return d.Std()
+	}
+	return 2 * time.Second
+}
+
+// TLSConfig points at a user-supplied certificate and key. When both are empty,
+// a self-signed certificate is generated and kept under /etc/linuxio/tls.
+type TLSConfig struct {
+	CertFile   string           `yaml:"cert_file" json:"cert_file"`
+	KeyFile    string           `yaml:"key_file" json:"key_file"`
+	ClientAuth ClientAuthConfig `yaml:"client_auth" json:"client_auth"`
+}
+
+// ClientAuthConfig enables login with client certificates signed by the CAs in CAFile.
+// Only certificates whose subject is listed in Users may log in.
+type ClientAuthConfig struct {
+	CAFile string           `yaml:"ca_file" json:"ca_file"`
+	Users  []ClientCertUser `yaml:"users" json:"users"`
+}
+
+// ClientCertUser maps a certificate subject (common name, or the full DN) to a local user.
+// Role overrides the role derived from the user's groups when set.
+type ClientCertUser struct {
+	Subject string `yaml:"subject" json:"subject"`
+	User    string `yaml:"user" json:"user"`
+	Role    string `yaml:"role" json:"role"`
+}
+
+// RBACConfig maps Unix groups to roles. Roles adds custom roles (name → permissions)
+// next to the built-in viewer, operator and admin. Users in no mapped group get DefaultRole.
+type RBACConfig struct {
+	DefaultRole string              `yaml:"default_role" json:"default_role"`
+	GroupRoles  map[string]string   `yaml:"group_roles" json:"group_roles"`
+	Roles       map[string][]string `yaml:"roles" json:"roles"`
+}
+
+// SessionConfig controls how long sessions live. Activity (HTTP or websocket)
+// pushes the idle expiry forward, but never past MaxLifetime from login.
+type SessionConfig struct {
+	IdleTimeout   Duration `yaml:"idle_timeout" json:"idle_timeout"`
+	MaxLifetime   Duration `yaml:"max_lifetime" json:"max_lifetime"`
+	ExpiryWarning Duration `yaml:"expiry_warning" json:"expiry_warning"` // websocket notice before expiry
+}
+
+type AuthConfig struct {
+	// Require TOTP for users that would get a privileged (sudo) session
+	Require2FAPrivileged bool          `yaml:"require_2fa_privileged" json:"require_2fa_privileged"`
+	Lockout              LockoutConfig `yaml:"lockout" json:"lockout"`
+	// How long an on-demand sudo elevation lasts before dropping back, 0 to keep it for the session
+	ElevationTimeout Duration `yaml:"elevation_timeout" json:"elevation_timeout"`
+}
+
+// LockoutConfig limits failed logins per client IP and per username.
+// Each lockout of the same source doubles in length, up to MaxLockout.
+type LockoutConfig struct {
+	Window             Duration `yaml:"window" json:"window"`
+	MaxFailuresPerIP   int      `yaml:"max_failures_per_ip" json:"max_failures_per_ip"`
+	MaxFailuresPerUser int      `yaml:"max_failures_per_user" json:"max_failures_per_user"`
+	BaseLockout        Duration `yaml:"base_lockout" json:"base_lockout"`
+	MaxLockout         Duration `yaml:"max_lockout" json:"max_lockout"`
+}
+
+// Duration is a time.Duration written as "90s" / "15m" in YAML and JSON.
+type Duration time.Duration
+
+func (d Duration) Std() time.Duration { return time.Duration(d) }
+
+func (d Duration) MarshalYAML() (any, error) { return time.Duration(d).String(), nil }
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	parsed, err := time.ParseDuration(value.Value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) { return json.Marshal(time.Duration(d).String()) }
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+var (
+	serverConfig   = defaultServerConfig()
+	serverConfigMu sync.RWMutex
+)
+
+func defaultServerConfig() ServerConfig {
+	return ServerConfig{
+		Auth: AuthConfig{
+			Require2FAPrivileged: false,
+			Lockout: LockoutConfig{
+				Window:             Duration(15 * time.Minute),
+				MaxFailuresPerIP:   20,
+				MaxFailuresPerUser: 5,
+				BaseLockout:        Duration(time.Minute),
+				MaxLockout:         Duration(time.Hour),
+			},
+			ElevationTimeout: Duration(15 * time.Minute),
+		},
+		Session: SessionConfig{
+			IdleTimeout:   Duration(30 * time.Minute),
+			MaxLifetime:   Duration(12 * time.Hour),
+			ExpiryWarning: Duration(2 * time.Minute),
+		},
+		Bridge: BridgeConfig{
+			Timeouts: map[string]Duration{
+				"default":              Duration(30 * time.Second),
+				"control":              Duration(5 * time.Second),
+				"control.capabilities": Duration(15 * time.Second),
+				"docker":               Duration(time.Minute),
+				"dbus.GetUpdates":      Duration(2 * time.Minute),
+				"dbus.InstallPackage":  Duration(10 * time.Minute),
+			},
+		},
+		Metrics: MetricsConfig{
+			Intervals: map[string]Duration{
+				"default":        Duration(2 * time.Second),
+				"system.sensors": Duration(5 * time.Second),
+				"docker.stats":   Duration(5 * time.Second),
+			},
+		},
+		RBAC: RBACConfig{
+			DefaultRole: "viewer",
+			GroupRoles: map[string]string{
+				"sudo":   "admin",
+				"wheel":  "admin",
+				"docker": "operator",
+			},
+		},
+	}
+}
+
+// LoadServerConfig reads serverConfig.yaml on top of the defaults
+func LoadServerConfig() error {
+	cfg := defaultServerConfig()
+
+	data, err := os.ReadFile(serverConfigPath)
+	if os.IsNotExist(err) {
+		logger.Warnf("No serverConfig.yaml found, using defaults")
+	} else if err != nil {
+		logger.Errorf("Failed to read serverConfig.yaml: %v", err)
+		return err
+	} else if err := yaml.Unmarshal(data, &cfg); err != nil {
+		logger.Errorf("Failed to parse serverConfig.yaml: %v", err)
+		return err
+	}
+
+	serverConfigMu.Lock()
+	serverConfig = cfg
+	serverConfigMu.Unlock()
+
+	logger.Infof("Server config loaded from %s", serverConfigPath)
+	return nil
+}
+
+// GetServerConfig returns a copy of the current server config
+func GetServerConfig() ServerConfig {
+	serverConfigMu.RLock()
+	defer serverConfigMu.RUnlock()
+	return serverConfig
+}
+
+// SaveServerConfig replaces the current server config and writes it to disk
+func SaveServerConfig(cfg ServerConfig) error {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		logger.Errorf("Failed to encode server config: %v", err)
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(serverConfigPath), 0755); err != nil {
+		logger.Errorf("Failed to create config directory: %v", err)
+		return err
+	}
+	if err := os.WriteFile(serverConfigPath, data, 0600); err != nil {
+		logger.Errorf("Failed to write serverConfig.yaml: %v", err)
+		return err
+	}
+
+	serverConfigMu.Lock()
+	serverConfig = cfg
+	serverConfigMu.Unlock()
+
+	logger.Infof("Server config saved to %s", serverConfigPath)
+	return nil
+}
@@ -8,8 +8,10 @@ import (
 	"regexp"
 	"strings"
 
+	"go-backend/internal/auth"
 	"go-backend/internal/config"
 	"go-backend/internal/logger"
+	"go-backend/internal/rbac"
 
 	"github.com/gin-gonic/gin"
 )
@@ -192,7 +194,11 @@ func ListComposeProjects(c *gin.Context) {
 }
 
 func RegisterDockerComposeRoutes(router *gin.Engine) {
-	docker := router.Group("/docker/compose")
+	docker := router.Group("/docker/compose",
+		auth.AuthMiddleware(),
+		auth.RequirePermission(rbac.PermSystemView),
+		auth.RequireWritePermission(rbac.PermDockerManage),
+	)
 	{
 		docker.GET("/projects", ListComposeProjects)
 		docker.POST("/:project/up", ComposeUp)
@@ -0,0 +1,167 @@
+package dockers
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go-backend/internal/bridge/bridgetest"
+	"go-backend/internal/config"
+	"go-backend/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeDocker stands in for the docker CLI: it records "<project dir> <args>" for each run
+// and fails with $FAKE_DOCKER_FAIL if set.
+const fakeDocker = `#!/bin/sh
+echo "$(basename "$PWD") $*" >> "$HOME/docker-calls"
+if [ -n "$FAKE_DOCKER_FAIL" ]; then
+	echo "$FAKE_DOCKER_FAIL" >&2
+	exit 1
+fi
+echo "NAME STATUS"
+`
+
+func TestComposeRoutes(t *testing.T) {
+	manage := []string{rbac.PermSystemView, rbac.PermDockerManage}
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		perms      []string
+		fail       string // makes docker fail with this message
+		wantStatus int
+		wantDocker []string
+	}{
+		{
+			name:       "list projects",
+			method:     http.MethodGet,
+			path:       "/docker/compose/projects",
+			perms:      []string{rbac.PermSystemView},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "up",
+			method:     http.MethodPost,
+			path:       "/docker/compose/web/up",
+			perms:      manage,
+			wantStatus: http.StatusOK,
+			wantDocker: []string{"web compose up -d"},
+		},
+		{
+			name:       "up without a compose file",
+			method:     http.MethodPost,
+			path:       "/docker/compose/empty/up",
+			perms:      manage,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "up failing",
+			method:     http.MethodPost,
+			path:       "/docker/compose/web/up",
+			perms:      manage,
+			fail:       "pull access denied",
+			wantStatus: http.StatusInternalServerError,
+			wantDocker: []string{"web compose up -d"},
+		},
+		{
+			name:       "down",
+			method:     http.MethodPost,
+			path:       "/docker/compose/web/down",
+			perms:      manage,
+			wantStatus: http.StatusOK,
+			wantDocker: []string{"web compose down"},
+		},
+		{
+			name:       "restart",
+			method:     http.MethodPost,
+			path:       "/docker/compose/web/restart",
+			perms:      manage,
+			wantStatus: http.StatusOK,
+			wantDocker: []string{"web compose restart"},
+		},
+		{
+			name:       "status",
+			method:     http.MethodGet,
+			path:       "/docker/compose/web/status",
+			perms:      []string{rbac.PermSystemView},
+			wantStatus: http.StatusOK,
+			wantDocker: []string{"web compose ps"},
+		},
+		{
+			name:       "invalid project name",
+			method:     http.MethodPost,
+			path:       "/docker/compose/web.old/down",
+			perms:      manage,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "viewer may not start projects",
+			method:     http.MethodPost,
+			path:       "/docker/compose/web/up",
+			perms:      []string{rbac.PermSystemView},
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := setupComposeHome(t)
+			t.Setenv("FAKE_DOCKER_FAIL", tt.fail)
+			sess, b := bridgetest.Setup(t, tt.perms...)
+			r := gin.New()
+			RegisterDockerComposeRoutes(r)
+
+			rec := bridgetest.Do(r, sess, tt.method, tt.path, nil)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body: %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if got := dockerCalls(t, home); !reflect.DeepEqual(got, tt.wantDocker) {
+				t.Fatalf("docker runs = %q, want %q", got, tt.wantDocker)
+			}
+			if got := b.Commands(); got != nil {
+				t.Fatalf("bridge calls = %+v, want none", got)
+			}
+		})
+	}
+}
+
+// setupComposeHome makes a home directory whose apps directory has the projects "web",
+// which has a compose file, and "empty", which doesn't, and puts the fake docker first on PATH.
+func setupComposeHome(t *testing.T) string {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	apps, err := config.GetDockerAppsDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin := t.TempDir()
+	for _, dir := range []string{filepath.Join(apps, "web"), filepath.Join(apps, "empty")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(apps, "web", "compose.yaml"), []byte("services: {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(bin, "docker"), []byte(fakeDocker), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return home
+}
+
+func dockerCalls(t *testing.T, home string) []string {
+	data, err := os.ReadFile(filepath.Join(home, "docker-calls"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
@@ -4,6 +4,7 @@ import (
 	"go-backend/internal/auth"
 	"go-backend/internal/bridge"
 	"go-backend/internal/logger"
+	"go-backend/internal/rbac"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -14,13 +15,13 @@ func ListContainers(c *gin.Context) {
 	if sess == nil {
 		return
 	}
-	data, err := bridge.CallWithSession(sess, "docker", "list_containers", nil)
+	data, err := bridge.Call(c.Request.Context(), sess, "docker", "list_containers", nil)
 	if err != nil {
 		logger.Errorf("Bridge ListContainers: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		bridge.WriteError(c, err)
 		return
 	}
-	c.Data(http.StatusOK, "application/json", data)
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "output": data})
 }
 
 func StartContainer(c *gin.Context) {
@@ -29,13 +30,13 @@ func StartContainer(c *gin.Context) {
 		return
 	}
 	id := c.Param("id")
-	data, err := bridge.CallWithSession(sess, "docker", "start_container", []string{id})
+	data, err := bridge.Call(c.Request.Context(), sess, "docker", "start_container", []string{id})
 	if err != nil {
 		logger.Errorf("Bridge StartContainer: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		bridge.WriteError(c, err)
 		return
 	}
-	c.Data(http.StatusOK, "application/json", data)
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "output": data})
 }
 
 func StopContainer(c *gin.Context) {
@@ -44,13 +45,13 @@ func StopContainer(c *gin.Context) {
 		return
 	}
 	id := c.Param("id")
-	data, err := bridge.CallWithSession(sess, "docker", "stop_container", []string{id})
+	data, err := bridge.Call(c.Request.Context(), sess, "docker", "stop_container", []string{id})
 	if err != nil {
 		logger.Errorf("Bridge StopContainer: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		bridge.WriteError(c, err)
 		return
 	}
-	c.Data(http.StatusOK, "application/json", data)
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "output": data})
 }
 
 func RemoveContainer(c *gin.Context) {
@@ -59,13 +60,13 @@ func RemoveContainer(c *gin.Context) {
 		return
 	}
 	id := c.Param("id")
-	data, err := bridge.CallWithSession(sess, "docker", "remove_container", []string{id})
+	data, err := bridge.Call(c.Request.Context(), sess, "docker", "remove_container", []string{id})
 	if err != nil {
 		logger.Errorf("Bridge RemoveContainer: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		bridge.WriteError(c, err)
 		return
 	}
-	c.Data(http.StatusOK, "application/json", data)
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "output": data})
 }
 
 func RestartContainer(c *gin.Context) {
@@ -74,13 +75,13 @@ func RestartContainer(c *gin.Context) {
 		return
 	}
 	id := c.Param("id")
-	data, err := bridge.CallWithSession(sess, "docker", "restart_container", []string{id})
+	data, err := bridge.Call(c.Request.Context(), sess, "docker", "restart_container", []string{id})
 	if err != nil {
 		logger.Errorf("Bridge RestartContainer: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		bridge.WriteError(c, err)
 		return
 	}
-	c.Data(http.StatusOK, "application/json", data)
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "output": data})
 }
 
 func ListImages(c *gin.Context) {
@@ -88,17 +89,22 @@ func ListImages(c *gin.Context) {
 	if sess == nil {
 		return
 	}
-	data, err := bridge.CallWithSession(sess, "docker", "list_images", nil)
+	data, err := bridge.Call(c.Request.Context(), sess, "docker", "list_images", nil)
 	if err != nil {
 		logger.Errorf("Bridge ListImages: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		bridge.WriteError(c, err)
 		return
 	}
-	c.Data(http.StatusOK, "application/json", data)
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "output": data})
 }
 
 func RegisterDockerRoutes(router *gin.Engine) {
-	docker := router.Group("/docker", auth.AuthMiddleware())
+	docker := router.Group("/docker",
+		auth.AuthMiddleware(),
+		auth.RequirePermission(rbac.PermSystemView),
+		auth.RequireWritePermission(rbac.PermDockerManage),
+		auth.RequireCapability(bridge.CapDocker),
+	)
 	{
 		docker.GET("/containers", ListContainers)
 		docker.POST("/containers/:id/start", StartContainer)
@@ -0,0 +1,123 @@
+package dockers
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"go-backend/internal/bridge"
+	"go-backend/internal/bridge/bridgetest"
+	"go-backend/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDockerRoutes(t *testing.T) {
+	manage := []string{rbac.PermSystemView, rbac.PermDockerManage}
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		perms      []string
+		script     func(b *bridgetest.Bridge)
+		wantStatus int
+		wantCalls  []bridgetest.Call
+	}{
+		{
+			name:   "list containers",
+			method: http.MethodGet,
+			path:   "/docker/containers",
+			perms:  []string{rbac.PermSystemView},
+			script: func(b *bridgetest.Bridge) {
+				b.Respond("docker", "list_containers", []map[string]any{{"Id": "abc123", "State": "running"}})
+			},
+			wantStatus: http.StatusOK,
+			wantCalls:  []bridgetest.Call{{Type: "docker", Command: "list_containers"}},
+		},
+		{
+			name:       "list images",
+			method:     http.MethodGet,
+			path:       "/docker/images",
+			perms:      []string{rbac.PermSystemView},
+			script:     func(b *bridgetest.Bridge) { b.Respond("docker", "list_images", []map[string]any{}) },
+			wantStatus: http.StatusOK,
+			wantCalls:  []bridgetest.Call{{Type: "docker", Command: "list_images"}},
+		},
+		{
+			name:       "start container",
+			method:     http.MethodPost,
+			path:       "/docker/containers/abc123/start",
+			perms:      manage,
+			script:     func(b *bridgetest.Bridge) { b.Respond("docker", "start_container", "started") },
+			wantStatus: http.StatusOK,
+			wantCalls:  []bridgetest.Call{{Type: "docker", Command: "start_container", Args: []string{"abc123"}}},
+		},
+		{
+			name:       "restart container",
+			method:     http.MethodPost,
+			path:       "/docker/containers/abc123/restart",
+			perms:      manage,
+			script:     func(b *bridgetest.Bridge) { b.Respond("docker", "restart_container", "restarted") },
+			wantStatus: http.StatusOK,
+			wantCalls:  []bridgetest.Call{{Type: "docker", Command: "restart_container", Args: []string{"abc123"}}},
+		},
+		{
+			name:       "remove container",
+			method:     http.MethodDelete,
+			path:       "/docker/containers/abc123",
+			perms:      manage,
+			script:     func(b *bridgetest.Bridge) { b.Respond("docker", "remove_container", "removed") },
+			wantStatus: http.StatusOK,
+			wantCalls:  []bridgetest.Call{{Type: "docker", Command: "remove_container", Args: []string{"abc123"}}},
+		},
+		{
+			name:   "remove missing container",
+			method: http.MethodDelete,
+			path:   "/docker/containers/gone",
+			perms:  manage,
+			script: func(b *bridgetest.Bridge) {
+				b.Fail("docker", "remove_container", bridge.CodeNotFound, "no such container: gone")
+			},
+			wantStatus: http.StatusNotFound,
+			wantCalls:  []bridgetest.Call{{Type: "docker", Command: "remove_container", Args: []string{"gone"}}},
+		},
+		{
+			name:       "viewer may not stop containers",
+			method:     http.MethodPost,
+			path:       "/docker/containers/abc123/stop",
+			perms:      []string{rbac.PermSystemView},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:   "no access to the docker socket",
+			method: http.MethodGet,
+			path:   "/docker/containers",
+			perms:  []string{rbac.PermSystemView},
+			script: func(b *bridgetest.Bridge) {
+				report := bridgetest.AllCapabilities()
+				report.Capabilities[bridge.CapDocker] = bridge.Capability{Present: true, Access: bridge.AccessNone, Error: "no access to the docker socket"}
+				b.Respond("control", "capabilities", report)
+			},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sess, b := bridgetest.Setup(t, tt.perms...)
+			if tt.script != nil {
+				tt.script(b)
+			}
+			r := gin.New()
+			RegisterDockerRoutes(r)
+
+			rec := bridgetest.Do(r, sess, tt.method, tt.path, nil)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body: %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if got := b.Commands(); !reflect.DeepEqual(got, tt.wantCalls) {
+				t.Fatalf("bridge calls = %+v, want %+v", got, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// ContainerStats is the resource usage of one running container.
+type ContainerStats struct {
+	ID         string  `json:"id"`
+	Name       string  `json:"name"`
+	CPUPercent float64 `json:"cpu_percent"` // of one CPU, so up to 100 × CPUs
+	MemUsage   uint64  `json:"mem_usage"`
+	MemLimit   uint64  `json:"mem_limit"`
+	NetInput   uint64  `json:"net_input"`
+	NetOutput  uint64  `json:"net_output"`
+	BlockRead  uint64  `json:"block_read"`
+	BlockWrite uint64  `json:"block_write"`
+}
+
+// cpuUsage is a container's CPU counters at one sample.
+type cpuUsage struct {
+	total, system uint64
+	at            time.Time
+}
+
+// dockerSampler reads one-shot stats of the running containers. One-shot stats carry no
+// previous CPU reading, so CPU usage is computed against the sampler's own last sample.
+type dockerSampler struct {
+	mu   sync.Mutex
+	cli  *client.Client      // created on first use and kept; it reconnects by itself
+	last map[string]cpuUsage // container ID → counters
+}
+
+func (s *dockerSampler) sample(ctx context.Context) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cli == nil {
+		cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+		if err != nil {
+			return nil, err
+		}
+		s.cli = cli
+	}
+	cli := s.cli
+
+	containers, err := cli.ContainerList(ctx, container.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	current := make(map[string]cpuUsage, len(containers))
+	stats := make([]ContainerStats, 0, len(containers))
+	for _, ctr := range containers {
+		st, usage, err := containerStats(ctx, cli, ctr.ID)
+		if err != nil {
+			continue // stopped meanwhile
+		}
+		if len(ctr.Names) > 0 {
+			st.Name = strings.TrimPrefix(ctr.Names[0], "/")
+		}
+		if prev, ok := s.last[ctr.ID]; ok && usage.at.Sub(prev.at) < staleAfter &&
+			usage.total >= prev.total && usage.system > prev.system {
+			st.CPUPercent = float64(usage.total-prev.total) / float64(usage.system-prev.system) * float64(st.cpus()) * 100
+		}
+		current[ctr.ID] = usage
+		stats = append(stats, st.ContainerStats)
+	}
+	s.last = current
+	return stats, nil
+}
+
+// rawStats is ContainerStats plus what's needed to compute CPU usage.
+type rawStats struct {
+	ContainerStats
+	onlineCPUs int
+}
+
+func (r rawStats) cpus() int {
+	if r.onlineCPUs > 0 {
+		return r.onlineCPUs
+	}
+	return 1
+}
+
+func containerStats(ctx context.Context, cli *client.Client, id string) (rawStats, cpuUsage, error) {
+	resp, err := cli.ContainerStatsOneShot(ctx, id)
+	if err != nil {
+		return rawStats{}, cpuUsage{}, err
+	}
+	defer resp.Body.Close()
+
+	var raw struct {
+		CPUStats struct {
+			CPUUsage struct {
+				TotalUsage uint64 `json:"total_usage"`
+			} `json:"cpu_usage"`
+			SystemCPUUsage uint64 `json:"system_cpu_usage"`
+			OnlineCPUs     int    `json:"online_cpus"`
+		} `json:"cpu_stats"`
+		MemoryStats struct {
+			Usage uint64 `json:"usage"`
+			Limit uint64 `json:"limit"`
+		} `json:"memory_stats"`
+		Networks map[string]struct {
+			RxBytes uint64 `json:"rx_bytes"`
+			TxBytes uint64 `json:"tx_bytes"`
+		} `json:"networks"`
+		BlkioStats struct {
+			IoServiceBytesRecursive []struct {
+				Op    string `json:"op"`
+				Value uint64 `json:"value"`
+			} `json:"io_service_bytes_recursive"`
+		} `json:"blkio_stats"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return rawStats{}, cpuUsage{}, err
+	}
+
+	st := rawStats{
+		ContainerStats: ContainerStats{
+			ID:       id,
+			MemUsage: raw.MemoryStats.Usage,
+			MemLimit: raw.MemoryStats.Limit,
+		},
+		onlineCPUs: raw.CPUStats.OnlineCPUs,
+	}
+	for _, n := range raw.Networks {
+		st.NetInput += n.RxBytes
+		st.NetOutput += n.TxBytes
+	}
+	for _, entry := range raw.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			st.BlockRead += entry.Value
+		case "write":
+			st.BlockWrite += entry.Value
+		}
+	}
+	usage := cpuUsage{total: raw.CPUStats.CPUUsage.TotalUsage, system: raw.CPUStats.SystemCPUUsage, at: time.Now()}
+	return st, usage, nil
+}
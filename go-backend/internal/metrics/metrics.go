@@ -0,0 +1,100 @@
+// Package metrics samples host and container metrics for the websocket channels, so the
+// frontend can subscribe instead of polling the REST endpoints.
+package metrics
+
+import (
+	"context"
+	"go-backend/internal/bridge"
+	"go-backend/internal/config"
+	"go-backend/internal/rbac"
+	"go-backend/internal/system"
+	"go-backend/internal/websocket"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+// Channels published by this package.
+const (
+	ChannelCPU         = "system.cpu"
+	ChannelMemory      = "system.mem"
+	ChannelNetwork     = "system.net"
+	ChannelSensors     = "system.sensors"
+	ChannelDockerStats = "docker.stats"
+)
+
+// RegisterPublishers registers a websocket publisher for every channel. Sampling only
+// runs while a channel has subscribers.
+func RegisterPublishers() {
+	register(ChannelCPU, "", func(context.Context) (any, error) { return system.FetchCPUInfo() })
+	register(ChannelMemory, "", func(context.Context) (any, error) { return system.FetchMemoryInfo() })
+	register(ChannelSensors, "", func(context.Context) (any, error) { return system.FetchSensorsInfo(), nil })
+	register(ChannelNetwork, "", (&netSampler{}).sample)
+	// Sampled with the server's access to Docker, so only users whose bridge can reach it
+	// may see the result, as with the /docker routes
+	register(ChannelDockerStats, bridge.CapDocker, (&dockerSampler{}).sample)
+}
+
+func register(channel, capability string, sample func(context.Context) (any, error)) {
+	interval := func() time.Duration { return config.GetServerConfig().Metrics.Interval(channel) }
+	websocket.RegisterPublisher(channel, rbac.PermSystemView, capability, interval, sample)
+}
+
+// InterfaceRates is the traffic of one network interface since the previous sample.
+type InterfaceRates struct {
+	Name      string  `json:"name"`
+	RxBytes   uint64  `json:"rx_bytes"` // totals since boot
+	TxBytes   uint64  `json:"tx_bytes"`
+	RxSpeed   float64 `json:"rx_speed"` // bytes per second
+	TxSpeed   float64 `json:"tx_speed"`
+	RxErrors  uint64  `json:"rx_errors"`
+	TxErrors  uint64  `json:"tx_errors"`
+	RxDropped uint64  `json:"rx_dropped"`
+	TxDropped uint64  `json:"tx_dropped"`
+}
+
+// staleAfter is how old a previous sample may be to compute rates from.
+const staleAfter = time.Minute
+
+// netSampler turns interface counters into rates. The first sample after subscribing has none.
+type netSampler struct {
+	mu   sync.Mutex
+	last map[string]net.IOCountersStat
+	at   time.Time
+}
+
+func (s *netSampler) sample(ctx context.Context) (any, error) {
+	counters, err := net.IOCountersWithContext(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elapsed := now.Sub(s.at).Seconds()
+	rates := make([]InterfaceRates, 0, len(counters))
+	current := make(map[string]net.IOCountersStat, len(counters))
+	for _, c := range counters {
+		current[c.Name] = c
+		r := InterfaceRates{
+			Name:      c.Name,
+			RxBytes:   c.BytesRecv,
+			TxBytes:   c.BytesSent,
+			RxErrors:  c.Errin,
+			TxErrors:  c.Errout,
+			RxDropped: c.Dropin,
+			TxDropped: c.Dropout,
+		}
+		// Counters reset when an interface is re-created, and after a pause in sampling a rate
+		// would average over the pause
+		if prev, ok := s.last[c.Name]; ok && elapsed > 0 && elapsed < staleAfter.Seconds() && c.BytesRecv >= prev.BytesRecv && c.BytesSent >= prev.BytesSent {
+			r.RxSpeed = float64(c.BytesRecv-prev.BytesRecv) / elapsed
+			r.TxSpeed = float64(c.BytesSent-prev.BytesSent) / elapsed
+		}
+		rates = append(rates, r)
+	}
+	s.last, s.at = current, now
+	return rates, nil
+}
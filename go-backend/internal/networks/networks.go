@@ -1,18 +1,23 @@
 package networks
 
 import (
-	"encoding/json"
 	"go-backend/cmd/bridge/dbus"
 	"go-backend/internal/auth"
 	"go-backend/internal/bridge"
 	"go-backend/internal/logger"
+	"go-backend/internal/rbac"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
 func RegisterNetworkRoutes(router *gin.Engine) {
-	network := router.Group("/network")
+	network := router.Group("/network",
+		auth.AuthMiddleware(),
+		auth.RequirePermission(rbac.PermSystemView),
+		auth.RequireWritePermission(rbac.PermNetworkManage),
+		auth.RequireCapability(bridge.CapNetworkManager),
+	)
 	{
 		network.GET("/info", getNetworkInfo)
 		network.POST("/set-dns", postSetDNS)
@@ -32,29 +37,10 @@ func getNetworkInfo(c *gin.Context) {
 	}
 	logger.Infof("%s requested network info (session: %s)", sess.User.ID, sess.SessionID)
 
-	rawResp, err := bridge.CallWithSession(sess, "dbus", "GetNetworkInfo", nil)
-	if err != nil {
-		logger.Errorf("Bridge call failed: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "bridge call failed", "detail": err.Error(), "output": rawResp})
-		return
-	}
-
-	var resp bridge.BridgeResponse
-	if err := json.Unmarshal([]byte(rawResp), &resp); err != nil {
-		logger.Errorf("Invalid bridge response: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid bridge response", "detail": err.Error(), "output": rawResp})
-		return
-	}
-	if resp.Status != "ok" {
-		logger.Warnf("Bridge returned error: %v", resp.Error)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": resp.Error, "output": string(resp.Output)})
-		return
-	}
-
 	var data []dbus.NMInterfaceInfo
-	if err := json.Unmarshal(resp.Output, &data); err != nil {
-		logger.Errorf("Invalid output structure: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid output structure", "detail": err.Error(), "output": string(resp.Output)})
+	if err := bridge.CallInto(c.Request.Context(), sess, "dbus", "GetNetworkInfo", nil, &data); err != nil {
+		logger.Errorf("Failed to get network info via bridge: %v", err)
+		bridge.WriteError(c, err)
 		return
 	}
 	logger.Debugf("Successfully returned %d interfaces to %s", len(data), sess.User.ID)
@@ -76,10 +62,10 @@ func postSetDNS(c *gin.Context) {
 		return
 	}
 	logger.Infof("%s sets DNS on %s: %v", sess.User.Name, req.Interface, req.DNS)
-	err := dbus.SetDNS(req.Interface, req.DNS)
+	_, err := bridge.Call(c.Request.Context(), sess, "dbus", "SetDNS", append([]string{req.Interface}, req.DNS...))
 	if err != nil {
 		logger.Errorf("Failed to set DNS on %s: %v", req.Interface, err)
-		c.JSON(500, gin.H{"error": err.Error()})
+		bridge.WriteError(c, err)
 		return
 	}
 	logger.Infof("Set DNS on %s to %v (user: %s, session: %s)", req.Interface, req.DNS, sess.User.Name, sess.SessionID)
@@ -101,10 +87,10 @@ func postSetGateway(c *gin.Context) {
 		return
 	}
 	logger.Infof("%s sets gateway on %s: %s", sess.User.Name, req.Interface, req.Gateway)
-	err := dbus.SetGateway(req.Interface, req.Gateway)
+	_, err := bridge.Call(c.Request.Context(), sess, "dbus", "SetGateway", []string{req.Interface, req.Gateway})
 	if err != nil {
 		logger.Errorf("Failed to set gateway on %s: %v", req.Interface, err)
-		c.JSON(500, gin.H{"error": err.Error()})
+		bridge.WriteError(c, err)
 		return
 	}
 	logger.Infof("Set gateway on %s to %s (user: %s, session: %s)", req.Interface, req.Gateway, sess.User.Name, sess.SessionID)
@@ -126,10 +112,10 @@ func postSetMTU(c *gin.Context) {
 		return
 	}
 	logger.Infof("%s sets MTU on %s: %s", sess.User.Name, req.Interface, req.MTU)
-	err := dbus.SetMTU(req.Interface, req.MTU)
+	_, err := bridge.Call(c.Request.Context(), sess, "dbus", "SetMTU", []string{req.Interface, req.MTU})
 	if err != nil {
 		logger.Errorf("Failed to set MTU on %s: %v", req.Interface, err)
-		c.JSON(500, gin.H{"error": err.Error()})
+		bridge.WriteError(c, err)
 		return
 	}
 	logger.Infof("Set MTU on %s to %s (user: %s, session: %s)", req.Interface, req.MTU, sess.User.Name, sess.SessionID)
@@ -150,10 +136,10 @@ func postSetIPv4DHCP(c *gin.Context) {
 		return
 	}
 	logger.Infof("%s requests IPv4 DHCP on %s", sess.User.Name, req.Interface)
-	err := dbus.SetIPv4DHCP(req.Interface)
+	_, err := bridge.Call(c.Request.Context(), sess, "dbus", "SetIPv4", []string{req.Interface, "dhcp"})
 	if err != nil {
 		logger.Errorf("Failed to set IPv4 DHCP on %s: %v", req.Interface, err)
-		c.JSON(500, gin.H{"error": err.Error()})
+		bridge.WriteError(c, err)
 		return
 	}
 	logger.Infof("Set IPv4 DHCP on %s (user: %s, session: %s)", req.Interface, sess.User.Name, sess.SessionID)
@@ -175,10 +161,10 @@ func postSetIPv4Static(c *gin.Context) {
 		return
 	}
 	logger.Infof("%s sets IPv4 static on %s: %s", sess.User.Name, req.Interface, req.AddressCIDR)
-	err := dbus.SetIPv4Static(req.Interface, req.AddressCIDR)
+	_, err := bridge.Call(c.Request.Context(), sess, "dbus", "SetIPv4", []string{req.Interface, "static", req.AddressCIDR})
 	if err != nil {
 		logger.Errorf("Failed to set IPv4 static on %s: %v", req.Interface, err)
-		c.JSON(500, gin.H{"error": err.Error()})
+		bridge.WriteError(c, err)
 		return
 	}
 	logger.Infof("Set IPv4 static on %s to %s (user: %s, session: %s)", req.Interface, req.AddressCIDR, sess.User.Name, sess.SessionID)
@@ -199,10 +185,10 @@ func postSetIPv6DHCP(c *gin.Context) {
 		return
 	}
 	logger.Infof("%s requests IPv6 DHCP on %s", sess.User.Name, req.Interface)
-	err := dbus.SetIPv6DHCP(req.Interface)
+	_, err := bridge.Call(c.Request.Context(), sess, "dbus", "SetIPv6", []string{req.Interface, "dhcp"})
 	if err != nil {
 		logger.Errorf("Failed to set IPv6 DHCP on %s: %v", req.Interface, err)
-		c.JSON(500, gin.H{"error": err.Error()})
+		bridge.WriteError(c, err)
 		return
 	}
 	logger.Infof("Set IPv6 DHCP on %s (user: %s, session: %s)", req.Interface, sess.User.Name, sess.SessionID)
@@ -224,10 +210,10 @@ func postSetIPv6Static(c *gin.Context) {
 		return
 	}
 	logger.Infof("%s sets IPv6 static on %s: %s", sess.User.Name, req.Interface, req.AddressCIDR)
-	err := dbus.SetIPv6Static(req.Interface, req.AddressCIDR)
+	_, err := bridge.Call(c.Request.Context(), sess, "dbus", "SetIPv6", []string{req.Interface, "static", req.AddressCIDR})
 	if err != nil {
 		logger.Errorf("Failed to set IPv6 static on %s: %v", req.Interface, err)
-		c.JSON(500, gin.H{"error": err.Error()})
+		bridge.WriteError(c, err)
 		return
 	}
 	logger.Infof("Set IPv6 static on %s to %s (user: %s, session: %s)", req.Interface, req.AddressCIDR, sess.User.Name, sess.SessionID)
@@ -0,0 +1,156 @@
+package networks
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"go-backend/internal/bridge"
+	"go-backend/internal/bridge/bridgetest"
+	"go-backend/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNetworkRoutes(t *testing.T) {
+	manage := []string{rbac.PermSystemView, rbac.PermNetworkManage}
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       any
+		perms      []string
+		script     func(b *bridgetest.Bridge)
+		wantStatus int
+		wantCalls  []bridgetest.Call
+	}{
+		{
+			name:   "network info",
+			method: http.MethodGet,
+			path:   "/network/info",
+			perms:  []string{rbac.PermSystemView},
+			script: func(b *bridgetest.Bridge) {
+				b.Respond("dbus", "GetNetworkInfo", []map[string]any{{"name": "eth0", "state": 100}})
+			},
+			wantStatus: http.StatusOK,
+			wantCalls:  []bridgetest.Call{{Type: "dbus", Command: "GetNetworkInfo"}},
+		},
+		{
+			name:       "set dns",
+			method:     http.MethodPost,
+			path:       "/network/set-dns",
+			body:       map[string]any{"interface": "eth0", "dns": []string{"1.1.1.1", "9.9.9.9"}},
+			perms:      manage,
+			script:     func(b *bridgetest.Bridge) { b.Respond("dbus", "SetDNS", nil) },
+			wantStatus: http.StatusOK,
+			wantCalls:  []bridgetest.Call{{Type: "dbus", Command: "SetDNS", Args: []string{"eth0", "1.1.1.1", "9.9.9.9"}}},
+		},
+		{
+			name:       "set dns without servers",
+			method:     http.MethodPost,
+			path:       "/network/set-dns",
+			body:       map[string]any{"interface": "eth0"},
+			perms:      manage,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "set gateway",
+			method:     http.MethodPost,
+			path:       "/network/set-gateway",
+			body:       map[string]any{"interface": "eth0", "gateway": "192.168.1.1"},
+			perms:      manage,
+			script:     func(b *bridgetest.Bridge) { b.Respond("dbus", "SetGateway", nil) },
+			wantStatus: http.StatusOK,
+			wantCalls:  []bridgetest.Call{{Type: "dbus", Command: "SetGateway", Args: []string{"eth0", "192.168.1.1"}}},
+		},
+		{
+			name:       "set gateway without an interface",
+			method:     http.MethodPost,
+			path:       "/network/set-gateway",
+			body:       map[string]any{"gateway": "192.168.1.1"},
+			perms:      manage,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "ipv4 dhcp",
+			method:     http.MethodPost,
+			path:       "/network/set-ipv4-dhcp",
+			body:       map[string]any{"interface": "eth0"},
+			perms:      manage,
+			script:     func(b *bridgetest.Bridge) { b.Respond("dbus", "SetIPv4", nil) },
+			wantStatus: http.StatusOK,
+			wantCalls:  []bridgetest.Call{{Type: "dbus", Command: "SetIPv4", Args: []string{"eth0", "dhcp"}}},
+		},
+		{
+			name:       "static ipv4",
+			method:     http.MethodPost,
+			path:       "/network/set-ipv4-static",
+			body:       map[string]any{"interface": "eth0", "address_cidr": "192.168.1.10/24"},
+			perms:      manage,
+			script:     func(b *bridgetest.Bridge) { b.Respond("dbus", "SetIPv4", nil) },
+			wantStatus: http.StatusOK,
+			wantCalls:  []bridgetest.Call{{Type: "dbus", Command: "SetIPv4", Args: []string{"eth0", "static", "192.168.1.10/24"}}},
+		},
+		{
+			name:   "ipv6 dhcp rejected by the bridge",
+			method: http.MethodPost,
+			path:   "/network/set-ipv6-dhcp",
+			body:   map[string]any{"interface": "eth9"},
+			perms:  manage,
+			script: func(b *bridgetest.Bridge) {
+				b.Fail("dbus", "SetIPv6", bridge.CodeInvalidArgs, "unknown interface eth9")
+			},
+			wantStatus: http.StatusBadRequest,
+			wantCalls:  []bridgetest.Call{{Type: "dbus", Command: "SetIPv6", Args: []string{"eth9", "dhcp"}}},
+		},
+		{
+			name:       "static ipv6",
+			method:     http.MethodPost,
+			path:       "/network/set-ipv6-static",
+			body:       map[string]any{"interface": "eth0", "address_cidr": "2001:db8::10/64"},
+			perms:      manage,
+			script:     func(b *bridgetest.Bridge) { b.Respond("dbus", "SetIPv6", nil) },
+			wantStatus: http.StatusOK,
+			wantCalls:  []bridgetest.Call{{Type: "dbus", Command: "SetIPv6", Args: []string{"eth0", "static", "2001:db8::10/64"}}},
+		},
+		{
+			name:   "static ipv6 failing in networkmanager",
+			method: http.MethodPost,
+			path:   "/network/set-ipv6-static",
+			body:   map[string]any{"interface": "eth0", "address_cidr": "2001:db8::10/64"},
+			perms:  manage,
+			script: func(b *bridgetest.Bridge) {
+				b.Fail("dbus", "SetIPv6", bridge.CodeInternal, "activation failed")
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantCalls:  []bridgetest.Call{{Type: "dbus", Command: "SetIPv6", Args: []string{"eth0", "static", "2001:db8::10/64"}}},
+		},
+		{
+			name:       "viewer may not change the mtu",
+			method:     http.MethodPost,
+			path:       "/network/set-mtu",
+			body:       map[string]any{"interface": "eth0", "mtu": "9000"},
+			perms:      []string{rbac.PermSystemView},
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sess, b := bridgetest.Setup(t, tt.perms...)
+			if tt.script != nil {
+				tt.script(b)
+			}
+			r := gin.New()
+			RegisterNetworkRoutes(r)
+
+			rec := bridgetest.Do(r, sess, tt.method, tt.path, tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body: %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if got := b.Commands(); !reflect.DeepEqual(got, tt.wantCalls) {
+				t.Fatalf("bridge calls = %+v, want %+v", got, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"go-backend/internal/auth"
 	"go-backend/internal/bridge"
 	"go-backend/internal/logger"
+	"go-backend/internal/rbac"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -11,21 +12,17 @@ import (
 
 func RegisterPowerRoutes(r *gin.Engine) {
 	group := r.Group("/power")
-	group.Use(auth.AuthMiddleware())
+	group.Use(auth.AuthMiddleware(), auth.RequirePermission(rbac.PermPowerManage))
 
 	group.POST("/reboot", func(c *gin.Context) {
 		sess := auth.GetSessionOrAbort(c)
 		if sess == nil {
 			return
 		}
-		output, err := bridge.CallWithSession(sess, "dbus", "Reboot", nil)
+		output, err := bridge.Call(c.Request.Context(), sess, "dbus", "Reboot", nil)
 		if err != nil {
 			logger.Errorf("Reboot failed: %+v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":  "reboot failed",
-				"detail": err.Error(),
-				"output": output,
-			})
+			bridge.WriteError(c, err)
 			return
 		}
 		logger.Infof("Reboot triggered successfully for user %s (session: %s)", sess.User.ID, sess.SessionID)
@@ -37,14 +34,10 @@ func RegisterPowerRoutes(r *gin.Engine) {
 		if sess == nil {
 			return
 		}
-		output, err := bridge.CallWithSession(sess, "dbus", "PowerOff", nil)
+		output, err := bridge.Call(c.Request.Context(), sess, "dbus", "PowerOff", nil)
 		if err != nil {
 			logger.Errorf("Shutdown failed: %+v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":  "shutdown failed",
-				"detail": err.Error(),
-				"output": output,
-			})
+			bridge.WriteError(c, err)
 			return
 		}
 		logger.Infof("Shutdown triggered successfully for user %s (session: %s)", sess.User.ID, sess.SessionID)
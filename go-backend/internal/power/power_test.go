@@ -0,0 +1,78 @@
+package power
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"go-backend/internal/bridge"
+	"go-backend/internal/bridge/bridgetest"
+	"go-backend/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPowerRoutes(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		perms      []string
+		script     func(b *bridgetest.Bridge)
+		wantStatus int
+		wantCalls  []bridgetest.Call
+	}{
+		{
+			name:       "reboot",
+			path:       "/power/reboot",
+			perms:      []string{rbac.PermPowerManage},
+			script:     func(b *bridgetest.Bridge) { b.Respond("dbus", "Reboot", nil) },
+			wantStatus: http.StatusOK,
+			wantCalls:  []bridgetest.Call{{Type: "dbus", Command: "Reboot"}},
+		},
+		{
+			name:  "shutdown refused by logind",
+			path:  "/power/shutdown",
+			perms: []string{rbac.PermPowerManage},
+			script: func(b *bridgetest.Bridge) {
+				b.Fail("dbus", "PowerOff", bridge.CodePermissionDenied, "interactive authentication required")
+			},
+			wantStatus: http.StatusForbidden,
+			wantCalls:  []bridgetest.Call{{Type: "dbus", Command: "PowerOff"}},
+		},
+		{
+			name:  "bridge down",
+			path:  "/power/reboot",
+			perms: []string{rbac.PermPowerManage},
+			script: func(b *bridgetest.Bridge) {
+				b.Fail("dbus", "Reboot", bridge.CodeUnavailable, "bridge connection closed")
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantCalls:  []bridgetest.Call{{Type: "dbus", Command: "Reboot"}},
+		},
+		{
+			name:       "viewer may not reboot",
+			path:       "/power/reboot",
+			perms:      []string{rbac.PermSystemView},
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sess, b := bridgetest.Setup(t, tt.perms...)
+			if tt.script != nil {
+				tt.script(b)
+			}
+			r := gin.New()
+			RegisterPowerRoutes(r)
+
+			rec := bridgetest.Do(r, sess, http.MethodPost, tt.path, nil)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body: %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if got := b.Commands(); !reflect.DeepEqual(got, tt.wantCalls) {
+				t.Fatalf("bridge calls = %+v, want %+v", got, tt.wantCalls)
+			}
+		})
+	}
+}
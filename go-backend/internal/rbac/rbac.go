@@ -0,0 +1,185 @@
+package rbac
+
+import (
+	"go-backend/internal/config"
+	"go-backend/internal/logger"
+	"os/user"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// Permissions checked by the HTTP routes and the bridge.
+const (
+	PermSystemView     = "system.view"     // read-only access to system, service, network, docker and update info
+	PermServicesManage = "services.manage" // start/stop/enable systemd units
+	PermNetworkManage  = "network.manage"  // change interface configuration
+	PermDockerManage   = "docker.manage"   // manage containers and compose projects
+	PermUpdatesManage  = "updates.manage"  // install packages and change update settings
+	PermPowerManage    = "power.manage"    // reboot and shut down the host
+	PermSettingsManage = "settings.manage" // server-wide auth settings and lockouts
+	PermAll            = "*"
+)
+
+// Built-in roles. Custom roles are defined in serverConfig.yaml.
+const (
+	RoleViewer   = "viewer"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+var builtinRoles = map[string][]string{
+	RoleViewer:   {PermSystemView},
+	RoleOperator: {PermSystemView, PermServicesManage, PermDockerManage, PermUpdatesManage},
+	RoleAdmin:    {PermAll},
+}
+
+// RolePermissions returns the permissions of a built-in or configured custom role.
+func RolePermissions(role string) ([]string, bool) {
+	if perms, ok := builtinRoles[role]; ok {
+		return perms, true
+	}
+	perms, ok := config.GetServerConfig().RBAC.Roles[role]
+	return perms, ok
+}
+
+// Resolve maps the user's Unix groups to roles and returns the roles and the union of their permissions.
+func Resolve(username string) ([]string, []string) {
+	cfg := config.GetServerConfig().RBAC
+
+	roles := []string{}
+	for _, group := range userGroups(username) {
+		if role, ok := cfg.GroupRoles[group]; ok && !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+	if len(roles) == 0 && cfg.DefaultRole != "" {
+		roles = append(roles, cfg.DefaultRole)
+	}
+	sort.Strings(roles)
+
+	perms := []string{}
+	for _, role := range roles {
+		rolePerms, ok := RolePermissions(role)
+		if !ok {
+			logger.Warnf("Unknown role %q mapped for user %s, ignoring", role, username)
+			continue
+		}
+		for _, p := range rolePerms {
+			if !slices.Contains(perms, p) {
+				perms = append(perms, p)
+			}
+		}
+	}
+	sort.Strings(perms)
+	return roles, perms
+}
+
+func userGroups(username string) []string {
+	u, err := user.Lookup(username)
+	if err != nil {
+		logger.Warnf("Failed to look up user %s: %v", username, err)
+		return nil
+	}
+	gids, err := u.GroupIds()
+	if err != nil {
+		logger.Warnf("Failed to look up groups of user %s: %v", username, err)
+		return nil
+	}
+
+	names := make([]string, 0, len(gids))
+	for _, gid := range gids {
+		if g, err := user.LookupGroupId(gid); err == nil {
+			names = append(names, g.Name)
+		}
+	}
+	return names
+}
+
+// Has reports whether perms grant perm. An empty perm is always granted.
+func Has(perms []string, perm string) bool {
+	return perm == "" || slices.Contains(perms, PermAll) || slices.Contains(perms, perm)
+}
+
+// commandPermissions lists the permission each bridge command needs, by request type.
+var commandPermissions = map[string]map[string]string{
+	"dbus": {
+		"Reboot":         PermPowerManage,
+		"PowerOff":       PermPowerManage,
+		"GetUpdates":     PermSystemView,
+		"InstallPackage": PermUpdatesManage,
+		"ListServices":   PermSystemView,
+		"GetServiceInfo": PermSystemView,
+		"StartService":   PermServicesManage,
+		"StopService":    PermServicesManage,
+		"RestartService": PermServicesManage,
+		"ReloadService":  PermServicesManage,
+		"EnableService":  PermServicesManage,
+		"DisableService": PermServicesManage,
+		"MaskService":    PermServicesManage,
+		"UnmaskService":  PermServicesManage,
+		"GetNetworkInfo": PermSystemView,
+		"SetDNS":         PermNetworkManage,
+		"SetGateway":     PermNetworkManage,
+		"SetMTU":         PermNetworkManage,
+		"SetIPv4":        PermNetworkManage,
+		"SetIPv6":        PermNetworkManage,
+	},
+	"control": {
+		"shutdown":     "",
+		"hello":        "",
+		"schema":       "",
+		"capabilities": "",
+	},
+	"modules": {
+		"list": PermSystemView,
+	},
+	"system": {
+		"get_drive_info": PermSystemView,
+		"get_smart_info": PermSystemView,
+		"get_nvme_power": PermSystemView,
+	},
+	"docker": {
+		"list_containers":   PermSystemView,
+		"start_container":   PermDockerManage,
+		"stop_container":    PermDockerManage,
+		"remove_container":  PermDockerManage,
+		"restart_container": PermDockerManage,
+		"list_images":       PermSystemView,
+	},
+}
+
+var (
+	modulePermissionsMu sync.RWMutex
+	modulePermissions   = map[string]map[string]string{}
+)
+
+// IsBuiltinType reports whether reqType is a request type of the built-in bridge commands.
+func IsBuiltinType(reqType string) bool {
+	_, ok := commandPermissions[reqType]
+	return ok
+}
+
+// RegisterCommandPermission sets the permission of a module command, as declared in its manifest.
+func RegisterCommandPermission(reqType, command, perm string) {
+	modulePermissionsMu.Lock()
+	defer modulePermissionsMu.Unlock()
+	if modulePermissions[reqType] == nil {
+		modulePermissions[reqType] = map[string]string{}
+	}
+	modulePermissions[reqType][command] = perm
+}
+
+// CommandPermission returns the permission a bridge command needs.
+// Commands not listed here or registered by a module are admin-only.
+func CommandPermission(reqType, command string) string {
+	if perm, ok := commandPermissions[reqType][command]; ok {
+		return perm
+	}
+	modulePermissionsMu.RLock()
+	defer modulePermissionsMu.RUnlock()
+	if perm, ok := modulePermissions[reqType][command]; ok {
+		return perm
+	}
+	return PermAll
+}
@@ -1,19 +1,24 @@
 package services
 
 import (
-	"encoding/json"
 	"net/http"
 	"regexp"
 
 	"go-backend/internal/auth"
 	"go-backend/internal/bridge"
 	"go-backend/internal/logger"
+	"go-backend/internal/rbac"
 
 	"github.com/gin-gonic/gin"
 )
 
 func RegisterServiceRoutes(router *gin.Engine) {
-	system := router.Group("/system", auth.AuthMiddleware())
+	system := router.Group("/system",
+		auth.AuthMiddleware(),
+		auth.RequirePermission(rbac.PermSystemView),
+		auth.RequireWritePermission(rbac.PermServicesManage),
+		auth.RequireCapability(bridge.CapSystemd),
+	)
 	{
 		system.GET("/services/status", getServiceStatus)
 		system.GET("/services/:name", getServiceDetail)
@@ -54,10 +59,10 @@ func serviceAction(c *gin.Context, action string) {
 	}
 	logger.Infof("User %s requested %s on %s (session: %s)", sess.User.Name, action, serviceName, sess.SessionID)
 
-	_, err := bridge.CallWithSession(sess, "dbus", action, []string{serviceName})
+	_, err := bridge.Call(c.Request.Context(), sess, "dbus", action, []string{serviceName})
 	if err != nil {
 		logger.Errorf("Failed to %s %s via bridge (user: %s, session: %s): %v", action, serviceName, sess.User.Name, sess.SessionID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		bridge.WriteError(c, err)
 		return
 	}
 	logger.Infof("%s on %s succeeded for user %s (session: %s)", action, serviceName, sess.User.Name, sess.SessionID)
@@ -71,32 +76,15 @@ func getServiceStatus(c *gin.Context) {
 	}
 	logger.Infof("User %s requested service status (session: %s)", sess.User.Name, sess.SessionID)
 
-	output, err := bridge.CallWithSession(sess, "dbus", "ListServices", nil)
+	output, err := bridge.Call(c.Request.Context(), sess, "dbus", "ListServices", nil)
 	if err != nil {
 		logger.Errorf("Failed to list services via bridge (user: %s, session: %s): %v", sess.User.Name, sess.SessionID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	var resp struct {
-		Status string          `json:"status"`
-		Output json.RawMessage `json:"output"`
-		Error  string          `json:"error"`
-	}
-	if err := json.Unmarshal([]byte(output), &resp); err != nil {
-		logger.Errorf("Failed to decode bridge response (user: %s): %v", sess.User.Name, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "decode bridge response"})
-		return
-	}
-
-	if resp.Status != "ok" {
-		logger.Warnf("Bridge returned error for service status (user: %s): %v", sess.User.Name, resp.Error)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": resp.Error})
+		bridge.WriteError(c, err)
 		return
 	}
 
 	logger.Debugf("Returned service status to user %s", sess.User.Name)
-	c.Data(http.StatusOK, "application/json", resp.Output)
+	c.Data(http.StatusOK, "application/json", output)
 }
 
 func getServiceDetail(c *gin.Context) {
@@ -107,27 +95,12 @@ func getServiceDetail(c *gin.Context) {
 	serviceName := c.Param("name")
 	logger.Infof("%s requested detail for %s (session: %s)", sess.User.Name, serviceName, sess.SessionID)
 
-	output, err := bridge.CallWithSession(sess, "dbus", "GetServiceInfo", []string{serviceName})
+	output, err := bridge.Call(c.Request.Context(), sess, "dbus", "GetServiceInfo", []string{serviceName})
 	if err != nil {
 		logger.Errorf("Failed to get info for %s via bridge (user: %s, session: %s): %v", serviceName, sess.User.Name, sess.SessionID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	var resp struct {
-		Status string          `json:"status"`
-		Output json.RawMessage `json:"output"`
-		Error  string          `json:"error"`
-	}
-	if err := json.Unmarshal([]byte(output), &resp); err != nil {
-		logger.Errorf("Failed to decode bridge response for %s (user: %s): %v", serviceName, sess.User.Name, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "decode bridge response"})
-		return
-	}
-	if resp.Status != "ok" {
-		logger.Warnf("Bridge returned error for %s (user: %s): %v", serviceName, sess.User.Name, resp.Error)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": resp.Error})
+		bridge.WriteError(c, err)
 		return
 	}
 	logger.Debugf("Returned detail for %s to user %s", serviceName, sess.User.Name)
-	c.Data(http.StatusOK, "application/json", resp.Output)
+	c.Data(http.StatusOK, "application/json", output)
 }
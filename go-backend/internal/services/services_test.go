@@ -0,0 +1,120 @@
+package services
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"go-backend/internal/bridge"
+	"go-backend/internal/bridge/bridgetest"
+	"go-backend/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestServiceRoutes(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		perms      []string
+		script     func(b *bridgetest.Bridge)
+		wantStatus int
+		wantCalls  []bridgetest.Call
+	}{
+		{
+			name:       "list services",
+			method:     http.MethodGet,
+			path:       "/system/services/status",
+			perms:      []string{rbac.PermSystemView},
+			script:     func(b *bridgetest.Bridge) { b.Respond("dbus", "ListServices", []string{"ssh.service"}) },
+			wantStatus: http.StatusOK,
+			wantCalls:  []bridgetest.Call{{Type: "dbus", Command: "ListServices"}},
+		},
+		{
+			name:   "service detail",
+			method: http.MethodGet,
+			path:   "/system/services/ssh.service",
+			perms:  []string{rbac.PermSystemView},
+			script: func(b *bridgetest.Bridge) {
+				b.Respond("dbus", "GetServiceInfo", map[string]string{"Id": "ssh.service"})
+			},
+			wantStatus: http.StatusOK,
+			wantCalls:  []bridgetest.Call{{Type: "dbus", Command: "GetServiceInfo", Args: []string{"ssh.service"}}},
+		},
+		{
+			name:       "unknown service",
+			method:     http.MethodGet,
+			path:       "/system/services/nope.service",
+			perms:      []string{rbac.PermSystemView},
+			script:     func(b *bridgetest.Bridge) { b.Fail("dbus", "GetServiceInfo", bridge.CodeNotFound, "no such unit") },
+			wantStatus: http.StatusNotFound,
+			wantCalls:  []bridgetest.Call{{Type: "dbus", Command: "GetServiceInfo", Args: []string{"nope.service"}}},
+		},
+		{
+			name:       "restart service",
+			method:     http.MethodPost,
+			path:       "/system/services/ssh.service/restart",
+			perms:      []string{rbac.PermSystemView, rbac.PermServicesManage},
+			script:     func(b *bridgetest.Bridge) { b.Respond("dbus", "RestartService", nil) },
+			wantStatus: http.StatusOK,
+			wantCalls:  []bridgetest.Call{{Type: "dbus", Command: "RestartService", Args: []string{"ssh.service"}}},
+		},
+		{
+			name:   "start refused by polkit",
+			method: http.MethodPost,
+			path:   "/system/services/ssh.service/start",
+			perms:  []string{rbac.PermSystemView, rbac.PermServicesManage},
+			script: func(b *bridgetest.Bridge) {
+				b.Fail("dbus", "StartService", bridge.CodePermissionDenied, "interactive authentication required")
+			},
+			wantStatus: http.StatusForbidden,
+			wantCalls:  []bridgetest.Call{{Type: "dbus", Command: "StartService", Args: []string{"ssh.service"}}},
+		},
+		{
+			name:       "invalid service name",
+			method:     http.MethodPost,
+			path:       "/system/services/ssh/stop",
+			perms:      []string{rbac.PermSystemView, rbac.PermServicesManage},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "viewer may not stop services",
+			method:     http.MethodPost,
+			path:       "/system/services/ssh.service/stop",
+			perms:      []string{rbac.PermSystemView},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:   "systemd unavailable",
+			method: http.MethodGet,
+			path:   "/system/services/status",
+			perms:  []string{rbac.PermSystemView},
+			script: func(b *bridgetest.Bridge) {
+				report := bridgetest.AllCapabilities()
+				report.Capabilities[bridge.CapSystemd] = bridge.Capability{Error: "systemd is not running"}
+				b.Respond("control", "capabilities", report)
+			},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sess, b := bridgetest.Setup(t, tt.perms...)
+			if tt.script != nil {
+				tt.script(b)
+			}
+			r := gin.New()
+			RegisterServiceRoutes(r)
+
+			rec := bridgetest.Do(r, sess, tt.method, tt.path, nil)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body: %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if got := b.Commands(); !reflect.DeepEqual(got, tt.wantCalls) {
+				t.Fatalf("bridge calls = %+v, want %+v", got, tt.wantCalls)
+			}
+		})
+	}
+}
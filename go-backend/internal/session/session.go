@@ -1,22 +1,85 @@
 package session
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"go-backend/internal/logger"
 	"go-backend/internal/utils"
+	"maps"
 	"net/http"
+	"sort"
+	"sync"
 	"time"
 )
 
 type Session struct {
-	SessionID  string
-	User       utils.User
-	ExpiresAt  time.Time
-	Privileged bool
+	SessionID     string
+	User          utils.User
+	ExpiresAt     time.Time
+	Privileged    bool
+	ElevatedUntil time.Time // when an on-demand elevation drops back, zero if not elevated
+	Roles         []string  // resolved from the user's Unix groups at login
+	Permissions   []string  // union of the roles' permissions
+	CSRFToken     string    // must accompany state-changing cookie-authenticated requests
+	TokenID       string    // set when the session was opened by an API token
+	Scopes        []string  // API token scopes, empty for interactive sessions
+	CreatedAt     time.Time
+	LastActivity  time.Time
+	MaxExpiresAt  time.Time // absolute lifetime; ExpiresAt slides with activity up to this
+	ClientIP      string
+	UserAgent     string
+	BridgeID      string // random name of the bridge's sockets, so paths don't reveal the session ID
+	BridgeSecret  string // proves the server and the bridge to each other; handed to the bridge on stdin
+}
+
+// activityResolution limits how often Touch rewrites the session store
+const activityResolution = 30 * time.Second
+
+var (
+	idleTimeout = 30 * time.Minute
+	maxLifetime = 12 * time.Hour
+)
+
+// SetTimeouts configures the idle timeout and absolute lifetime of new and renewed sessions
+func SetTimeouts(idle, max time.Duration) {
+	SessionMux <- func() {
+		idleTimeout = idle
+		maxLifetime = max
+	}
+}
+
+// MaxLifetime returns the configured absolute session lifetime
+func MaxLifetime() time.Duration {
+	done := make(chan time.Duration)
+	SessionMux <- func() {
+		done <- maxLifetime
+	}
+	return <-done
+}
+
+// newCSRFToken returns a random per-session CSRF token
+func newCSRFToken() string {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		panic("crypto/rand failed: " + err.Error())
+	}
+	return hex.EncodeToString(buf)
+}
+
+// idleDeadline is when the session expires if no further activity happens. Must run inside the session actor.
+func idleDeadline(sess Session, now time.Time) time.Time {
+	expires := now.Add(idleTimeout)
+	if !sess.MaxExpiresAt.IsZero() && expires.After(sess.MaxExpiresAt) {
+		expires = sess.MaxExpiresAt
+	}
+	return expires
 }
 
 var (
 	Sessions   = make(map[string]Session)
 	SessionMux = make(chan func())
+
+	store Store = MemoryStore{}
 )
 
 func init() {
@@ -34,6 +97,80 @@ func init() {
 	}()
 }
 
+// Init installs the session backend and restores any non-expired sessions from it.
+// Must be called before StartSessionGC.
+func Init(s Store) error {
+	loaded, err := s.Load()
+	if err != nil {
+		return err
+	}
+
+	done := make(chan int)
+	SessionMux <- func() {
+		store = s
+		now := time.Now()
+		restored := 0
+		for id, sess := range loaded {
+			if sess.ExpiresAt.After(now) {
+				// Sessions stored before CSRF tokens existed get one now
+				if sess.CSRFToken == "" && sess.TokenID == "" {
+					sess.CSRFToken = newCSRFToken()
+				}
+				Sessions[id] = sess
+				restored++
+			}
+		}
+		if len(loaded) > 0 {
+			persist()
+		}
+		done <- restored
+	}
+	if restored := <-done; restored > 0 {
+		logger.Infof("Restored %d sessions from store", restored)
+	}
+	return nil
+}
+
+// persistDelay batches the store writes of mutations that follow each other closely
+const persistDelay = time.Second
+
+var (
+	persistMu    sync.Mutex
+	pendingSave  map[string]Session // latest snapshot not yet written
+	pendingStore Store
+	persistTimer *time.Timer
+	saveMu       sync.Mutex // one store write at a time
+)
+
+// persist schedules a write of the current sessions to the store, so the actor never waits
+// on the disk. Must run inside the session actor.
+func persist() {
+	snapshot := maps.Clone(Sessions)
+	persistMu.Lock()
+	pendingSave, pendingStore = snapshot, store
+	if persistTimer == nil {
+		persistTimer = time.AfterFunc(persistDelay, flushSessions)
+	}
+	persistMu.Unlock()
+}
+
+// flushSessions writes the latest pending snapshot, if any, to the store.
+func flushSessions() {
+	saveMu.Lock()
+	defer saveMu.Unlock()
+
+	persistMu.Lock()
+	snapshot, s := pendingSave, pendingStore
+	pendingSave, pendingStore, persistTimer = nil, nil, nil
+	persistMu.Unlock()
+	if snapshot == nil {
+		return
+	}
+	if err := s.Save(snapshot); err != nil {
+		logger.Errorf("Failed to persist sessions: %v", err)
+	}
+}
+
 // Starts a goroutine that periodically checks for expired sessions
 func StartSessionGC() {
 	ticker := time.NewTicker(10 * time.Minute)
@@ -49,6 +186,7 @@ func StartSessionGC() {
 					}
 				}
 				if count > 0 {
+					persist()
 					logger.Infof("Garbage collected %d expired sessions", count)
 				}
 			}
@@ -57,15 +195,21 @@ func StartSessionGC() {
 }
 
 // Creates a new session
-func CreateSession(id string, user utils.User, duration time.Duration, privileged bool) {
-	sess := Session{
-		SessionID:  id,
-		User:       user,
-		ExpiresAt:  time.Now().Add(duration),
-		Privileged: privileged,
-	}
+func CreateSession(id string, user utils.User, privileged bool) {
 	SessionMux <- func() {
+		now := time.Now()
+		sess := Session{
+			SessionID:    id,
+			User:         user,
+			Privileged:   privileged,
+			CSRFToken:    newCSRFToken(),
+			CreatedAt:    now,
+			LastActivity: now,
+			MaxExpiresAt: now.Add(maxLifetime),
+		}
+		sess.ExpiresAt = idleDeadline(sess, now)
 		Sessions[id] = sess
+		persist()
 	}
 	logger.Infof("Created session for user '%s'", user.ID)
 
@@ -77,6 +221,7 @@ func DeleteSession(id string) {
 		sess, exists := Sessions[id]
 		if exists {
 			delete(Sessions, id)
+			persist()
 			logger.Infof("Deleted session for user '%s'", sess.User.ID)
 		}
 	}
@@ -143,13 +288,54 @@ func IsValid(id string) bool {
 	return valid
 }
 
-// Changes the privileged status of a session
+// Changes the privileged status of a session. Any pending elevation window is cleared.
 func SetPrivileged(sessionID string, privileged bool) {
 	SessionMux <- func() {
 		sess, exists := Sessions[sessionID]
 		if exists {
 			sess.Privileged = privileged
+			sess.ElevatedUntil = time.Time{}
+			Sessions[sessionID] = sess
+			persist()
+		}
+	}
+}
+
+// SetElevated marks a session privileged until the given time (zero for no limit)
+func SetElevated(sessionID string, until time.Time) {
+	SessionMux <- func() {
+		sess, exists := Sessions[sessionID]
+		if exists {
+			sess.Privileged = true
+			sess.ElevatedUntil = until
+			Sessions[sessionID] = sess
+			persist()
+		}
+	}
+}
+
+// SetBridgeIdentity records the socket name and secret of the session's current bridge
+func SetBridgeIdentity(sessionID, bridgeID, secret string) {
+	SessionMux <- func() {
+		sess, exists := Sessions[sessionID]
+		if exists {
+			sess.BridgeID = bridgeID
+			sess.BridgeSecret = secret
 			Sessions[sessionID] = sess
+			persist()
+		}
+	}
+}
+
+// SetRoles records the roles and permissions resolved for the session's user
+func SetRoles(sessionID string, roles, permissions []string) {
+	SessionMux <- func() {
+		sess, exists := Sessions[sessionID]
+		if exists {
+			sess.Roles = roles
+			sess.Permissions = permissions
+			Sessions[sessionID] = sess
+			persist()
 		}
 	}
 }
@@ -172,6 +358,90 @@ func GetActiveSessionIDs() []string {
 	return <-done
 }
 
+// CreateTokenSession creates a session owned by an API token, limited to the token's scopes.
+func CreateTokenSession(id, tokenID string, user utils.User, privileged bool, scopes []string) {
+	SessionMux <- func() {
+		now := time.Now()
+		sess := Session{
+			SessionID:    id,
+			User:         user,
+			Privileged:   privileged,
+			TokenID:      tokenID,
+			Scopes:       scopes,
+			CreatedAt:    now,
+			LastActivity: now,
+			MaxExpiresAt: now.Add(maxLifetime),
+		}
+		sess.ExpiresAt = idleDeadline(sess, now)
+		Sessions[id] = sess
+		persist()
+	}
+	logger.Infof("Created token session for user '%s' (token: %s)", user.ID, tokenID)
+}
+
+// SetClient records the client IP and user agent the session was opened from
+func SetClient(id, clientIP, userAgent string) {
+	SessionMux <- func() {
+		sess, exists := Sessions[id]
+		if exists {
+			sess.ClientIP = clientIP
+			sess.UserAgent = userAgent
+			Sessions[id] = sess
+			persist()
+		}
+	}
+}
+
+// Touch records activity on a session and slides its idle expiry forward,
+// never past its absolute lifetime. Expired sessions are not revived.
+func Touch(id string) {
+	SessionMux <- func() {
+		sess, exists := Sessions[id]
+		now := time.Now()
+		if exists && sess.ExpiresAt.After(now) && now.Sub(sess.LastActivity) >= activityResolution {
+			sess.LastActivity = now
+			sess.ExpiresAt = idleDeadline(sess, now)
+			Sessions[id] = sess
+			persist()
+		}
+	}
+}
+
+// ListByUser returns copies of the user's valid sessions, oldest first
+func ListByUser(userID string) []Session {
+	done := make(chan []Session)
+	SessionMux <- func() {
+		now := time.Now()
+		list := []Session{}
+		for _, s := range Sessions {
+			if s.User.ID == userID && s.ExpiresAt.After(now) {
+				list = append(list, s)
+			}
+		}
+		done <- list
+	}
+	list := <-done
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+// FindByToken returns a copy of the valid session opened by the given API token, or nil.
+func FindByToken(tokenID string) *Session {
+	done := make(chan *Session)
+	SessionMux <- func() {
+		now := time.Now()
+		for _, s := range Sessions {
+			if s.TokenID == tokenID && s.ExpiresAt.After(now) {
+				copy := s
+				done <- &copy
+				return
+			}
+		}
+		done <- nil
+	}
+	return <-done
+}
+
 // Get returns a pointer to the Session struct for the given sessionID, or nil if not found.
 // WARNING: The returned pointer is to a *copy*; do not modify fields directly!
 func Get(id string) *Session {
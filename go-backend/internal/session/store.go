@@ -0,0 +1,148 @@
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	defaultStorePath = "/var/lib/linuxio/sessions.enc"
+	defaultKeyPath   = "/etc/linuxio/session.key"
+)
+
+// Store is a pluggable session backend. Load is called once at startup; Save is called
+// by the background writer with a snapshot, shortly after the sessions change.
+type Store interface {
+	Load() (map[string]Session, error)
+	Save(sessions map[string]Session) error
+}
+
+// MemoryStore keeps nothing on disk: sessions are lost on restart.
+type MemoryStore struct{}
+
+func (MemoryStore) Load() (map[string]Session, error)      { return map[string]Session{}, nil }
+func (MemoryStore) Save(sessions map[string]Session) error { return nil }
+
+// FileStore persists sessions to disk, encrypted with AES-256-GCM.
+// The key is generated on first use and kept in a separate root-only file.
+type FileStore struct {
+	Path    string
+	KeyPath string
+
+	gcm cipher.AEAD
+}
+
+// NewFileStore returns a store at path, loading or generating its key once.
+func NewFileStore(path, keyPath string) (*FileStore, error) {
+	f := &FileStore{Path: path, KeyPath: keyPath}
+	gcm, err := f.loadCipher()
+	if err != nil {
+		return nil, err
+	}
+	f.gcm = gcm
+	return f, nil
+}
+
+// NewStoreFromEnv picks the backend from LINUXIO_SESSION_STORE ("file" or "memory").
+func NewStoreFromEnv() (Store, error) {
+	switch os.Getenv("LINUXIO_SESSION_STORE") {
+	case "memory":
+		return MemoryStore{}, nil
+	default:
+		path := os.Getenv("LINUXIO_SESSION_STORE_PATH")
+		if path == "" {
+			path = defaultStorePath
+		}
+		return NewFileStore(path, defaultKeyPath)
+	}
+}
+
+func (f *FileStore) Load() (map[string]Session, error) {
+	sessions := make(map[string]Session)
+
+	data, err := os.ReadFile(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return sessions, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session store: %w", err)
+	}
+
+	gcm := f.gcm
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("session store is truncated")
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt session store: %w", err)
+	}
+
+	if err := json.Unmarshal(plain, &sessions); err != nil {
+		return nil, fmt.Errorf("failed to parse session store: %w", err)
+	}
+	return sessions, nil
+}
+
+func (f *FileStore) Save(sessions map[string]Session) error {
+	plain, err := json.Marshal(sessions)
+	if err != nil {
+		return fmt.Errorf("failed to encode sessions: %w", err)
+	}
+
+	gcm := f.gcm
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	data := gcm.Seal(nonce, nonce, plain, nil)
+
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0700); err != nil {
+		return fmt.Errorf("failed to create session store directory: %w", err)
+	}
+	// Write to a temp file and rename, so a crash never leaves a half-written store
+	tmp := f.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write session store: %w", err)
+	}
+	if err := os.Rename(tmp, f.Path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to replace session store: %w", err)
+	}
+	return nil
+}
+
+// loadCipher loads the store key, generating it on first use.
+func (f *FileStore) loadCipher() (cipher.AEAD, error) {
+	key, err := os.ReadFile(f.KeyPath)
+	if errors.Is(err, os.ErrNotExist) {
+		key = make([]byte, 32)
+		if _, err := io.ReadFull(rand.Reader, key); err != nil {
+			return nil, fmt.Errorf("failed to generate session key: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(f.KeyPath), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create session key directory: %w", err)
+		}
+		if err := os.WriteFile(f.KeyPath, key, 0600); err != nil {
+			return nil, fmt.Errorf("failed to write session key: %w", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read session key: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("session key %s has invalid length %d", f.KeyPath, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
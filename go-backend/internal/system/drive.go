@@ -1,7 +1,6 @@
 package system
 
 import (
-	"encoding/json"
 	"net/http"
 
 	"go-backend/internal/auth"
@@ -17,28 +16,12 @@ func getDiskInfo(c *gin.Context) {
 		return
 	}
 
-	output, err := bridge.CallWithSession(sess, "system", "get_drive_info", nil)
+	output, err := bridge.Call(c.Request.Context(), sess, "system", "get_drive_info", nil)
 	if err != nil {
 		logger.Errorf("Failed to get drive info via bridge: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		bridge.WriteError(c, err)
 		return
 	}
 
-	var resp struct {
-		Status string          `json:"status"`
-		Output json.RawMessage `json:"output"`
-		Error  string          `json:"error"`
-	}
-	if err := json.Unmarshal([]byte(output), &resp); err != nil {
-		logger.Errorf("Failed to decode bridge response: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "decode bridge response"})
-		return
-	}
-
-	if resp.Status != "ok" {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": resp.Error})
-		return
-	}
-
-	c.Data(http.StatusOK, "application/json", resp.Output)
+	c.Data(http.StatusOK, "application/json", output)
 }
@@ -1,6 +1,8 @@
 package system
 
 import (
+	"go-backend/internal/auth"
+	"go-backend/internal/rbac"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -8,7 +10,7 @@ import (
 )
 
 func RegisterSystemRoutes(router *gin.Engine) {
-	system := router.Group("/system")
+	system := router.Group("/system", auth.AuthMiddleware(), auth.RequirePermission(rbac.PermSystemView))
 	{
 		system.GET("/info", getHostInfo)
 		system.GET("/cpu", getCPUInfo)
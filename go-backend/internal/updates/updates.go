@@ -9,23 +9,22 @@ import (
 	"go-backend/internal/auth"
 	"go-backend/internal/bridge"
 	"go-backend/internal/logger"
+	"go-backend/internal/rbac"
 
 	"github.com/gin-gonic/gin"
 )
 
-type BridgeResponse struct {
-	Status string          `json:"status"`
-	Output json.RawMessage `json:"output"`
-	Error  string          `json:"error"`
-}
-
 var validPackageName = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
 
 func RegisterUpdateRoutes(router *gin.Engine) {
-	system := router.Group("/system", auth.AuthMiddleware())
+	system := router.Group("/system",
+		auth.AuthMiddleware(),
+		auth.RequirePermission(rbac.PermSystemView),
+		auth.RequireWritePermission(rbac.PermUpdatesManage),
+	)
 	{
-		system.GET("/updates", getUpdatesHandler)
-		system.POST("/update", updatePackageHandler)
+		system.GET("/updates", auth.RequireCapability(bridge.CapPackageKit), getUpdatesHandler)
+		system.POST("/update", auth.RequireCapability(bridge.CapPackageKit), updatePackageHandler)
 		system.GET("/updates/update-history", getUpdateHistoryHandler)
 		system.GET("/updates/settings", getUpdateSettings)
 		system.POST("/updates/settings", postUpdateSettings)
@@ -40,42 +39,22 @@ func getUpdatesHandler(c *gin.Context) {
 		return
 	}
 
-	output, err := bridge.CallWithSession(sess, "dbus", "GetUpdates", nil)
+	output, err := bridge.Call(c.Request.Context(), sess, "dbus", "GetUpdates", nil)
 	if err != nil {
-		logger.Errorf("❌ Failed to get updates: %v\nOutput: %s", err, output)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "failed to get updates",
-			"details": err.Error(),
-			"output":  output,
-		})
+		logger.Errorf("❌ Failed to get updates: %v", err)
+		bridge.WriteError(c, err)
 		return
 	}
 
-	// 1. Unmarshal bridge response object
-	var resp struct {
-		Status string          `json:"status"`
-		Output json.RawMessage `json:"output"`
-		Error  string          `json:"error"`
-	}
-	if err := json.Unmarshal([]byte(output), &resp); err != nil {
-		logger.Errorf("❌ Failed to decode bridge response: %v\nOutput: %s", err, output)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "failed to decode bridge response",
-			"details": err.Error(),
-			"output":  output,
-		})
-		return
-	}
-
-	// 2. Defensive: If output is empty/null, treat as empty array
+	// Defensive: If output is empty/null, treat as empty array
 	updates := []Update{}
-	if string(resp.Output) != "null" && len(resp.Output) > 0 {
-		if err := json.Unmarshal(resp.Output, &updates); err != nil {
-			logger.Errorf("❌ Failed to decode updates JSON: %v\nOutput: %s", err, string(resp.Output))
+	if string(output) != "null" && len(output) > 0 {
+		if err := json.Unmarshal(output, &updates); err != nil {
+			logger.Errorf("❌ Failed to decode updates JSON: %v\nOutput: %s", err, string(output))
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "failed to decode updates JSON",
 				"details": err.Error(),
-				"output":  string(resp.Output),
+				"output":  string(output),
 			})
 			return
 		}
@@ -108,15 +87,10 @@ func updatePackageHandler(c *gin.Context) {
 		return
 	}
 
-	output, err := bridge.CallWithSession(sess, "dbus", "InstallPackage", []string{req.PackageID})
-
+	output, err := bridge.Call(c.Request.Context(), sess, "dbus", "InstallPackage", []string{req.PackageID})
 	if err != nil {
-		logger.Errorf("❌ Failed to update %s: %v\nOutput: %s", req.PackageID, err, output)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to update package",
-			"details": err.Error(),
-			"output":  output,
-		})
+		logger.Errorf("❌ Failed to update %s: %v", req.PackageID, err)
+		bridge.WriteError(c, err)
 		return
 	}
 
@@ -23,14 +23,20 @@ func getUpdateHistoryHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, history)
 }
 
+// Package manager logs the update history is read from, dpkg's first
+var (
+	dpkgLogPath = "/var/log/dpkg.log"
+	dnfLogPath  = "/var/log/dnf.log"
+)
+
 func parseUpdateHistory() []UpdateHistoryEntry {
-	if _, err := os.Stat("/var/log/dpkg.log"); err == nil {
+	if _, err := os.Stat(dpkgLogPath); err == nil {
 		logger.Infof("Parsing dpkg update history")
-		return parseDpkgLog("/var/log/dpkg.log")
+		return parseDpkgLog(dpkgLogPath)
 	}
-	if _, err := os.Stat("/var/log/dnf.log"); err == nil {
+	if _, err := os.Stat(dnfLogPath); err == nil {
 		logger.Infof("Parsing dnf update history")
-		return parseDnfHistory("/var/log/dnf.log")
+		return parseDnfHistory(dnfLogPath)
 	}
 	logger.Warnf("No known package manager log found")
 	return []UpdateHistoryEntry{}
@@ -0,0 +1,155 @@
+package updates
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go-backend/internal/bridge"
+	"go-backend/internal/bridge/bridgetest"
+	"go-backend/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestUpdateRoutes(t *testing.T) {
+	const pkg = "vim;2:9.1.0016-1;amd64;updates"
+	manage := []string{rbac.PermSystemView, rbac.PermUpdatesManage}
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       any
+		perms      []string
+		dpkgLog    string // contents of the dpkg log, none if empty
+		script     func(b *bridgetest.Bridge)
+		wantStatus int
+		wantCalls  []bridgetest.Call
+	}{
+		{
+			name:   "list updates",
+			method: http.MethodGet,
+			path:   "/system/updates",
+			perms:  []string{rbac.PermSystemView},
+			script: func(b *bridgetest.Bridge) {
+				b.Respond("dbus", "GetUpdates", []map[string]any{{"package_id": pkg, "summary": "Vi IMproved"}})
+			},
+			wantStatus: http.StatusOK,
+			wantCalls:  []bridgetest.Call{{Type: "dbus", Command: "GetUpdates"}},
+		},
+		{
+			name:       "no updates",
+			method:     http.MethodGet,
+			path:       "/system/updates",
+			perms:      []string{rbac.PermSystemView},
+			script:     func(b *bridgetest.Bridge) { b.Respond("dbus", "GetUpdates", nil) },
+			wantStatus: http.StatusOK,
+			wantCalls:  []bridgetest.Call{{Type: "dbus", Command: "GetUpdates"}},
+		},
+		{
+			name:   "install package with progress",
+			method: http.MethodPost,
+			path:   "/system/update",
+			body:   map[string]string{"package": pkg},
+			perms:  manage,
+			script: func(b *bridgetest.Bridge) {
+				b.Handle("dbus", "InstallPackage", func(ctx context.Context, args []string, progress func(bridge.Progress)) (any, error) {
+					progress(bridge.Progress{Percent: 50, Status: "downloading"})
+					return nil, nil
+				})
+			},
+			wantStatus: http.StatusOK,
+			wantCalls:  []bridgetest.Call{{Type: "dbus", Command: "InstallPackage", Args: []string{pkg}}},
+		},
+		{
+			name:       "package name instead of package id",
+			method:     http.MethodPost,
+			path:       "/system/update",
+			body:       map[string]string{"package": "vim"},
+			perms:      manage,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "packagekit unavailable",
+			method: http.MethodGet,
+			path:   "/system/updates",
+			perms:  []string{rbac.PermSystemView},
+			script: func(b *bridgetest.Bridge) {
+				report := bridgetest.AllCapabilities()
+				report.Capabilities[bridge.CapPackageKit] = bridge.Capability{Error: "org.freedesktop.PackageKit is not running"}
+				b.Respond("control", "capabilities", report)
+			},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "viewer may not install",
+			method:     http.MethodPost,
+			path:       "/system/update",
+			body:       map[string]string{"package": pkg},
+			perms:      []string{rbac.PermSystemView},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:   "update history from the dpkg log",
+			method: http.MethodGet,
+			path:   "/system/updates/update-history",
+			perms:  []string{rbac.PermSystemView},
+			dpkgLog: "2025-05-02 10:00:01 upgrade vim:amd64 2:9.1.0016-1 2:9.1.0016-2\n" +
+				"2025-05-02 10:00:02 install htop:amd64 <none> 3.3.0-4\n" +
+				"2025-05-02 10:00:03 configure htop:amd64 3.3.0-4 <none>\n",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "no update history",
+			method:     http.MethodGet,
+			path:       "/system/updates/update-history",
+			perms:      []string{rbac.PermSystemView},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "settings need no bridge",
+			method:     http.MethodGet,
+			path:       "/system/updates/settings",
+			perms:      []string{rbac.PermSystemView},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sess, b := bridgetest.Setup(t, tt.perms...)
+			setPackageLogs(t, tt.dpkgLog)
+			if tt.script != nil {
+				tt.script(b)
+			}
+			r := gin.New()
+			RegisterUpdateRoutes(r)
+
+			rec := bridgetest.Do(r, sess, tt.method, tt.path, tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body: %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if got := b.Commands(); !reflect.DeepEqual(got, tt.wantCalls) {
+				t.Fatalf("bridge calls = %+v, want %+v", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+// setPackageLogs points the update history at a dpkg log with the given contents, or at
+// no log at all, instead of the host's.
+func setPackageLogs(t *testing.T, dpkgLog string) {
+	dir := t.TempDir()
+	oldDpkg, oldDnf := dpkgLogPath, dnfLogPath
+	dpkgLogPath, dnfLogPath = filepath.Join(dir, "dpkg.log"), filepath.Join(dir, "dnf.log")
+	t.Cleanup(func() { dpkgLogPath, dnfLogPath = oldDpkg, oldDnf })
+
+	if dpkgLog != "" {
+		if err := os.WriteFile(dpkgLogPath, []byte(dpkgLog), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
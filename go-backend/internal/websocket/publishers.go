@@ -0,0 +1,128 @@
+package websocket
+
+import (
+	"context"
+	"go-backend/internal/bridge"
+	"go-backend/internal/logger"
+	"go-backend/internal/session"
+	"sync"
+	"time"
+)
+
+// publisher samples one channel's data on a timer while the channel has subscribers and
+// broadcasts every sample to all of them.
+type publisher struct {
+	channel  string
+	interval func() time.Duration // read each tick, so config changes apply without resubscribing
+	sample   func(ctx context.Context) (any, error)
+
+	mu     sync.Mutex
+	cancel context.CancelFunc // set while sampling
+	last   *bridge.Event      // latest sample, the snapshot sent to new subscribers
+}
+
+var (
+	publishers          = make(map[string]*publisher) // channel → publisher, filled before the server starts
+	channelCapabilities = make(map[string]string)     // channel → capability the subscriber's bridge must report usable
+)
+
+// RegisterPublisher makes channel a sampled channel that needs perm to subscribe to and,
+// unless capability is empty, a bridge reporting that capability as usable, like the REST
+// routes serving the same data. sample runs every interval() while anyone is subscribed.
+// Must be called before the server starts.
+func RegisterPublisher(channel, perm, capability string, interval func() time.Duration, sample func(ctx context.Context) (any, error)) {
+	publishers[channel] = &publisher{channel: channel, interval: interval, sample: sample}
+	channelPermissions[channel] = perm
+	if capability != "" {
+		channelCapabilities[channel] = capability
+	}
+}
+
+// channelUnavailable returns why the session's bridge can't serve channel, or "" if it can.
+// As with auth.RequireCapability, a missing capability report doesn't block the subscription.
+func channelUnavailable(ctx context.Context, sess *session.Session, channel string) string {
+	name, ok := channelCapabilities[channel]
+	if !ok {
+		return ""
+	}
+	report, err := bridge.Capabilities(ctx, sess, false)
+	if err != nil {
+		logger.Debugf("No capability report for session %s: %v", sess.SessionID, err)
+		return ""
+	}
+	if capability, ok := report.Capabilities[name]; ok && !capability.Usable() {
+		return name + " is not available: " + capability.Error
+	}
+	return ""
+}
+
+// start begins sampling unless already running.
+func (p *publisher) start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	logger.Debugf("Publisher for %s started", p.channel)
+	go p.run(ctx)
+}
+
+// stop ends sampling and forgets the last sample, which would be stale by the next subscribe.
+func (p *publisher) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cancel == nil {
+		return
+	}
+	p.cancel()
+	p.cancel = nil
+	p.last = nil
+	logger.Debugf("Publisher for %s stopped", p.channel)
+}
+
+func (p *publisher) run(ctx context.Context) {
+	for {
+		data, err := p.sample(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger.Warnf("Failed to sample %s: %v", p.channel, err)
+		} else if ev, err := bridge.NewEvent(p.channel, "sample", data); err != nil {
+			logger.Warnf("Failed to encode %s sample: %v", p.channel, err)
+		} else {
+			p.mu.Lock()
+			p.last = &ev
+			p.mu.Unlock()
+			broadcastToChannel(p.channel, "", WSResponse{Type: "event", Data: ev})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(p.interval()):
+		}
+	}
+}
+
+// snapshot returns the latest sample, if there is one yet.
+func (p *publisher) snapshot() *bridge.Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.last
+}
+
+// sendSnapshot gives a new subscriber of a sampled channel the latest sample right away
+// instead of making it wait for the next tick. A publisher that has just started has none;
+// its first sample goes to every subscriber anyway.
+func sendSnapshot(channel string, writeJSON func(any) error) {
+	p, ok := publishers[channel]
+	if !ok {
+		return
+	}
+	if ev := p.snapshot(); ev != nil {
+		_ = writeJSON(WSResponse{Type: "event", Data: ev})
+	}
+}
@@ -1,19 +1,47 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
 	"go-backend/internal/auth"
 	"go-backend/internal/bridge"
+	"go-backend/internal/config"
 	"go-backend/internal/logger"
+	"go-backend/internal/rbac"
+	"go-backend/internal/session"
+	"go-backend/internal/utils"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 )
 
 var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool { return true },
+	CheckOrigin: checkOrigin,
+}
+
+// checkOrigin only accepts websockets opened by pages served from this host,
+// plus the Vite dev server in development. Cookies alone would let any site open one.
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		logger.Warnf("Rejected websocket without Origin from %s", r.RemoteAddr)
+		return false
+	}
+	if os.Getenv("GO_ENV") == "development" && origin == "http://localhost:"+utils.GetDevPort() {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	logger.Warnf("Rejected websocket from origin %q (host: %s)", origin, r.Host)
+	return false
 }
 
 type WSMessage struct {
@@ -27,22 +55,42 @@ type WSResponse struct {
 	RequestID string      `json:"requestId,omitempty"`
 	Data      interface{} `json:"data,omitempty"`
 	Error     string      `json:"error,omitempty"`
+	Code      string      `json:"code,omitempty"`
 }
 
 // --- CHANNEL SUBSCRIPTION INFRASTRUCTURE ---
 
+// subscriber is a websocket subscribed to a channel.
+type subscriber struct {
+	sessionID string
+	publish   func(any) error // queues a droppable message, see connWriter.publish
+}
+
 var (
 	channelsMu         sync.Mutex
-	channelSubscribers = make(map[string]map[*websocket.Conn]struct{})
+	channelSubscribers = make(map[string]map[*websocket.Conn]subscriber)
 )
 
-func subscribe(conn *websocket.Conn, channel string) {
+// channelPermissions lists the permission needed to subscribe to a channel. Bridge event
+// channels carry host state, so they need the same permission as reading it. RegisterPublisher
+// adds the sampled channels.
+var channelPermissions = map[string]string{
+	bridge.ChannelSystemdUnits: rbac.PermSystemView,
+	bridge.ChannelDocker:       rbac.PermSystemView,
+	bridge.ChannelNetwork:      rbac.PermSystemView,
+	bridge.ChannelPackageKit:   rbac.PermSystemView,
+}
+
+func subscribe(conn *websocket.Conn, sub subscriber, channel string) {
 	channelsMu.Lock()
 	defer channelsMu.Unlock()
 	if channelSubscribers[channel] == nil {
-		channelSubscribers[channel] = make(map[*websocket.Conn]struct{})
+		channelSubscribers[channel] = make(map[*websocket.Conn]subscriber)
+	}
+	channelSubscribers[channel][conn] = sub
+	if p, ok := publishers[channel]; ok {
+		p.start()
 	}
-	channelSubscribers[channel][conn] = struct{}{}
 	logger.Infof("WebSocket subscribed to channel: %s", channel)
 }
 
@@ -52,7 +100,7 @@ func unsubscribe(conn *websocket.Conn, channel string) {
 	if subs, exists := channelSubscribers[channel]; exists && subs != nil {
 		delete(subs, conn)
 		if len(subs) == 0 {
-			delete(channelSubscribers, channel)
+			removeChannel(channel)
 		}
 	}
 	logger.Infof("WebSocket unsubscribed from channel: %s", channel)
@@ -64,23 +112,160 @@ func removeConnFromAllChannels(conn *websocket.Conn) {
 	for channel, subs := range channelSubscribers {
 		delete(subs, conn)
 		if len(subs) == 0 {
-			delete(channelSubscribers, channel)
+			removeChannel(channel)
 		}
 	}
 }
 
-func broadcastToChannel(channel string, msg WSResponse) {
+// removeChannel forgets a channel without subscribers and stops sampling it.
+// Must be called with channelsMu held.
+func removeChannel(channel string) {
+	delete(channelSubscribers, channel)
+	if p, ok := publishers[channel]; ok {
+		p.stop()
+	}
+}
+
+// broadcastToChannel sends msg to the channel's subscribers of one session, or of every
+// session if sessionID is empty.
+func broadcastToChannel(channel, sessionID string, msg WSResponse) {
 	channelsMu.Lock()
-	conns := make([]*websocket.Conn, 0, len(channelSubscribers[channel]))
-	for conn := range channelSubscribers[channel] {
-		conns = append(conns, conn)
+	writers := make([]func(any) error, 0, len(channelSubscribers[channel]))
+	for _, sub := range channelSubscribers[channel] {
+		if sessionID == "" || sub.sessionID == sessionID {
+			writers = append(writers, sub.publish)
+		}
 	}
 	channelsMu.Unlock()
-	for _, conn := range conns {
-		_ = conn.WriteJSON(msg) // Optionally handle errors
+	for _, publish := range writers {
+		_ = publish(msg)
+	}
+}
+
+// PublishBridgeEvent fans an event pushed by a session's bridge out to that session's
+// subscribers of the event's channel. Other sessions have their own bridge, which reports
+// the same host events to them.
+func PublishBridgeEvent(sessionID string, ev bridge.Event) {
+	broadcastToChannel(ev.Channel, sessionID, WSResponse{Type: "event", Data: ev})
+}
+
+// --- PER-SESSION EVENTS ---
+
+var (
+	sessionConnsMu sync.Mutex
+	sessionConns   = make(map[string]map[*websocket.Conn]func(any) error) // sessionID → conn → writeJSON
+)
+
+func addSessionConn(sessionID string, conn *websocket.Conn, writeJSON func(any) error) {
+	sessionConnsMu.Lock()
+	defer sessionConnsMu.Unlock()
+	if sessionConns[sessionID] == nil {
+		sessionConns[sessionID] = make(map[*websocket.Conn]func(any) error)
+	}
+	sessionConns[sessionID][conn] = writeJSON
+}
+
+func removeSessionConn(sessionID string, conn *websocket.Conn) {
+	sessionConnsMu.Lock()
+	defer sessionConnsMu.Unlock()
+	delete(sessionConns[sessionID], conn)
+	if len(sessionConns[sessionID]) == 0 {
+		delete(sessionConns, sessionID)
 	}
 }
 
+// NotifySession sends an event to every websocket the session has open.
+func NotifySession(sessionID, msgType string, data any) {
+	sessionConnsMu.Lock()
+	writers := make([]func(any) error, 0, len(sessionConns[sessionID]))
+	for _, writeJSON := range sessionConns[sessionID] {
+		writers = append(writers, writeJSON)
+	}
+	sessionConnsMu.Unlock()
+	for _, writeJSON := range writers {
+		_ = writeJSON(WSResponse{Type: msgType, Data: data})
+	}
+}
+
+// --- SESSION EXPIRY ---
+
+const expiryCheckInterval = 10 * time.Second
+
+// watchSessionExpiry warns the client shortly before its session expires, once per expiry deadline,
+// and tells it when the session is gone before closing the socket. Activity moves the deadline,
+// re-arming the warning.
+func watchSessionExpiry(sessionID string, w *connWriter, done <-chan struct{}) {
+	ticker := time.NewTicker(expiryCheckInterval)
+	defer ticker.Stop()
+
+	var warnedFor time.Time
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		sess := session.Get(sessionID)
+		now := time.Now()
+		if sess == nil || !sess.ExpiresAt.After(now) {
+			_ = w.send(WSResponse{Type: "session_expired"})
+			w.close(websocket.CloseNormalClosure, "session expired", true)
+			return
+		}
+
+		warning := config.GetServerConfig().Session.ExpiryWarning.Std()
+		left := sess.ExpiresAt.Sub(now)
+		if left <= warning && !sess.ExpiresAt.Equal(warnedFor) {
+			warnedFor = sess.ExpiresAt
+			_ = w.send(WSResponse{
+				Type: "session_expiring",
+				Data: gin.H{"expires_at": sess.ExpiresAt, "seconds_left": int(left.Seconds())},
+			})
+		}
+	}
+}
+
+// bridgeCalls tracks a connection's running bridgeCalls by request ID so the client can cancel them.
+type bridgeCalls struct {
+	mu      sync.Mutex
+	cancels map[string]*context.CancelFunc
+}
+
+// start returns the context of a new call and the function to call when it is over.
+func (b *bridgeCalls) start(parent context.Context, requestID string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(parent)
+	if requestID == "" {
+		return ctx, cancel // can't be cancelled by the client; ends with the connection
+	}
+	entry := &cancel
+	b.mu.Lock()
+	if prev, ok := b.cancels[requestID]; ok {
+		(*prev)()
+	}
+	b.cancels[requestID] = entry
+	b.mu.Unlock()
+
+	return ctx, func() {
+		cancel()
+		b.mu.Lock()
+		if b.cancels[requestID] == entry {
+			delete(b.cancels, requestID)
+		}
+		b.mu.Unlock()
+	}
+}
+
+func (b *bridgeCalls) cancel(requestID string) bool {
+	b.mu.Lock()
+	cancel, ok := b.cancels[requestID]
+	b.mu.Unlock()
+	if ok {
+		(*cancel)()
+	}
+	return ok
+}
+
 // --- MAIN HANDLER ---
 
 func WebSocketHandler(c *gin.Context) {
@@ -94,11 +279,28 @@ func WebSocketHandler(c *gin.Context) {
 		logger.Errorf("WS upgrade failed: %v", err)
 		return
 	}
+	done := make(chan struct{})
+	// Bridge calls still running when the socket closes are cancelled
+	ctx, cancelAll := context.WithCancel(context.Background())
+	calls := &bridgeCalls{cancels: make(map[string]*context.CancelFunc)}
+
+	// Replies, bridge calls, channels and the expiry watcher all write from their own
+	// goroutines; the writer serializes them and keeps a slow client from blocking them
+	w := newConnWriter(conn, sess.SessionID)
+	go w.run()
+	expectPongs(conn)
+	writeJSON := w.send
 	defer func() {
+		close(done)
+		cancelAll()
 		removeConnFromAllChannels(conn)
-		conn.Close()
+		removeSessionConn(sess.SessionID, conn)
+		w.close(websocket.CloseNormalClosure, "", false)
 	}()
 
+	go watchSessionExpiry(sess.SessionID, w, done)
+	addSessionConn(sess.SessionID, conn, writeJSON)
+
 	logger.Infof("WebSocket connected for user: %s (session: %s, privileged: %v)", sess.User.Name, sess.SessionID, sess.Privileged)
 
 	for {
@@ -108,9 +310,10 @@ func WebSocketHandler(c *gin.Context) {
 			break
 		}
 		logger.Infof("WS got message: %s", msg)
+		session.Touch(sess.SessionID)
 		var wsMsg WSMessage
 		if err := json.Unmarshal(msg, &wsMsg); err != nil {
-			_ = conn.WriteJSON(WSResponse{Type: "error", Error: "Invalid JSON"})
+			_ = writeJSON(WSResponse{Type: "error", Error: "Invalid JSON"})
 			continue
 		}
 
@@ -121,25 +324,47 @@ func WebSocketHandler(c *gin.Context) {
 				Channel string `json:"channel"`
 			}
 			if err := json.Unmarshal(wsMsg.Payload, &payload); err != nil || payload.Channel == "" {
-				_ = conn.WriteJSON(WSResponse{Type: "error", Error: "Missing channel"})
+				_ = writeJSON(WSResponse{Type: "error", Error: "Missing channel"})
 				continue
 			}
-			subscribe(conn, payload.Channel)
-			_ = conn.WriteJSON(WSResponse{Type: "subscribed", Data: payload.Channel})
+			if perm, ok := channelPermissions[payload.Channel]; ok && !rbac.Has(sess.Permissions, perm) {
+				logger.Warnf("Subscription to %s denied for user %s (missing %s)", payload.Channel, sess.User.ID, perm)
+				_ = writeJSON(WSResponse{Type: "error", Error: "permission denied: " + perm, Code: string(bridge.CodePermissionDenied)})
+				continue
+			}
+			if reason := channelUnavailable(ctx, sess, payload.Channel); reason != "" {
+				_ = writeJSON(WSResponse{Type: "error", Error: reason, Code: string(bridge.CodeUnavailable)})
+				continue
+			}
+			subscribe(conn, subscriber{sessionID: sess.SessionID, publish: w.publish}, payload.Channel)
+			_ = writeJSON(WSResponse{Type: "subscribed", Data: payload.Channel})
+			sendSnapshot(payload.Channel, writeJSON)
 
 		case "unsubscribe":
 			var payload struct {
 				Channel string `json:"channel"`
 			}
 			if err := json.Unmarshal(wsMsg.Payload, &payload); err != nil || payload.Channel == "" {
-				_ = conn.WriteJSON(WSResponse{Type: "error", Error: "Missing channel"})
+				_ = writeJSON(WSResponse{Type: "error", Error: "Missing channel"})
 				continue
 			}
 			unsubscribe(conn, payload.Channel)
-			_ = conn.WriteJSON(WSResponse{Type: "unsubscribed", Data: payload.Channel})
+			_ = writeJSON(WSResponse{Type: "unsubscribed", Data: payload.Channel})
+
+		case "renewSession":
+			current := session.Get(sess.SessionID)
+			if current == nil {
+				_ = writeJSON(WSResponse{Type: "session_expired", RequestID: wsMsg.RequestID})
+				continue
+			}
+			_ = writeJSON(WSResponse{
+				Type:      "renewSession_response",
+				RequestID: wsMsg.RequestID,
+				Data:      gin.H{"expires_at": current.ExpiresAt},
+			})
 
 		case "getUserInfo":
-			_ = conn.WriteJSON(WSResponse{
+			_ = writeJSON(WSResponse{
 				Type:      "getUserInfo_response",
 				RequestID: wsMsg.RequestID,
 				Data:      sess.User,
@@ -152,27 +377,61 @@ func WebSocketHandler(c *gin.Context) {
 				Args    []string `json:"args"`
 			}
 			if err := json.Unmarshal(wsMsg.Payload, &payload); err != nil {
-				_ = conn.WriteJSON(WSResponse{Type: "error", Error: "Invalid bridgeCall payload"})
+				_ = writeJSON(WSResponse{Type: "error", Error: "Invalid bridgeCall payload"})
 				continue
 			}
-			output, err := bridge.CallWithSession(sess, payload.ReqType, payload.Command, payload.Args)
-			if err != nil {
-				_ = conn.WriteJSON(WSResponse{
+			if perm := rbac.CommandPermission(payload.ReqType, payload.Command); !rbac.Has(sess.Permissions, perm) {
+				logger.Warnf("bridgeCall %s %s denied for user %s (missing %s)", payload.ReqType, payload.Command, sess.User.ID, perm)
+				_ = writeJSON(WSResponse{
 					Type:      wsMsg.Type + "_response",
 					RequestID: wsMsg.RequestID,
-					Error:     err.Error(),
-					Data:      output,
+					Error:     "permission denied: " + perm,
+					Code:      string(bridge.CodePermissionDenied),
 				})
 				continue
 			}
-			_ = conn.WriteJSON(WSResponse{
-				Type:      wsMsg.Type + "_response",
-				RequestID: wsMsg.RequestID,
-				Data:      output,
-			})
+			// Runs alongside the read loop so progress and other messages keep flowing during long commands
+			callCtx, finish := calls.start(ctx, wsMsg.RequestID)
+			go func(requestID string) {
+				defer finish()
+				runBridgeCall(callCtx, sess, requestID, payload.ReqType, payload.Command, payload.Args, w.send, w.publish)
+			}(wsMsg.RequestID)
+
+		case "cancel":
+			// Cancels the running bridgeCall with the same requestId
+			if !calls.cancel(wsMsg.RequestID) {
+				_ = writeJSON(WSResponse{Type: "error", RequestID: wsMsg.RequestID, Error: "No such call"})
+			}
 
 		default:
-			_ = conn.WriteJSON(WSResponse{Type: "error", Error: "Unknown message type"})
+			_ = writeJSON(WSResponse{Type: "error", Error: "Unknown message type"})
 		}
 	}
 }
+
+// runBridgeCall forwards a websocket bridgeCall to the session's bridge. Progress reported by
+// long-running commands is published as bridgeCall_progress messages, which a slow client may
+// miss; the bridgeCall_response is always sent.
+func runBridgeCall(ctx context.Context, sess *session.Session, requestID, reqType, command string, args []string, send, publish func(any) error) {
+	output, err := bridge.CallStream(ctx, sess, reqType, command, args, func(p bridge.Progress) {
+		_ = publish(WSResponse{
+			Type:      "bridgeCall_progress",
+			RequestID: requestID,
+			Data:      p,
+		})
+	})
+	if err != nil {
+		_ = send(WSResponse{
+			Type:      "bridgeCall_response",
+			RequestID: requestID,
+			Error:     err.Error(),
+			Code:      string(bridge.ErrorCodeOf(err)),
+		})
+		return
+	}
+	_ = send(WSResponse{
+		Type:      "bridgeCall_response",
+		RequestID: requestID,
+		Data:      output,
+	})
+}
@@ -0,0 +1,163 @@
+package websocket
+
+import (
+	"errors"
+	"go-backend/internal/logger"
+	"go-backend/internal/session"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// messages waiting to be written to one client
+	sendQueueSize = 64
+	// how long a single write may take before the client is considered gone
+	writeWait = 10 * time.Second
+	// the client must answer a ping within pongWait; pings go out a bit more often
+	pongWait   = 60 * time.Second
+	pingPeriod = pongWait * 9 / 10
+	// channel messages dropped in a row before a client that can't keep up is disconnected
+	maxDroppedInARow = 256
+)
+
+var errConnClosed = errors.New("websocket closed")
+
+// connWriter owns all writes to one websocket; gorilla/websocket allows only one writer at a time.
+// Messages go through a bounded queue, so a slow client can't block the goroutines producing
+// them: direct replies that don't fit disconnect it, channel messages that don't fit are dropped.
+type connWriter struct {
+	conn      *websocket.Conn
+	sessionID string
+	queue     chan any
+
+	closeOnce   sync.Once
+	closing     chan struct{} // closed by close
+	closeCode   int
+	closeReason string
+	flush       bool          // write what is queued before the close frame
+	stopped     chan struct{} // closed when run returns
+
+	droppedInARow atomic.Int32
+}
+
+func newConnWriter(conn *websocket.Conn, sessionID string) *connWriter {
+	return &connWriter{
+		conn:      conn,
+		sessionID: sessionID,
+		queue:     make(chan any, sendQueueSize),
+		closing:   make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+}
+
+// send queues a message the client must get, such as a reply. If the queue is full,
+// the client is disconnected.
+func (w *connWriter) send(v any) error {
+	select {
+	case <-w.stopped:
+		return errConnClosed
+	case w.queue <- v:
+		return nil
+	default:
+		logger.Warnf("WebSocket client of session %s is not reading, disconnecting", w.sessionID)
+		w.close(websocket.ClosePolicyViolation, "slow consumer", false)
+		return errConnClosed
+	}
+}
+
+// publish queues a channel message, which can be dropped: the next one supersedes it.
+// A client that misses too many in a row is disconnected.
+func (w *connWriter) publish(v any) error {
+	select {
+	case <-w.stopped:
+		return errConnClosed
+	case w.queue <- v:
+		w.droppedInARow.Store(0)
+		return nil
+	default:
+		if w.droppedInARow.Add(1) >= maxDroppedInARow {
+			logger.Warnf("WebSocket client of session %s dropped %d channel messages in a row, disconnecting", w.sessionID, maxDroppedInARow)
+			w.close(websocket.ClosePolicyViolation, "slow consumer", false)
+		}
+		return nil
+	}
+}
+
+// close ends the connection with a close frame. With flush, queued messages are written first.
+func (w *connWriter) close(code int, reason string, flush bool) {
+	w.closeOnce.Do(func() {
+		w.closeCode, w.closeReason, w.flush = code, reason, flush
+		close(w.closing)
+	})
+}
+
+// run writes queued messages and pings the client until the connection closes. Pings also
+// check the session, so a socket doesn't outlive it.
+func (w *connWriter) run() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		close(w.stopped)
+		w.conn.Close() // ends the read loop
+	}()
+
+	for {
+		select {
+		case v := <-w.queue:
+			if err := w.write(v); err != nil {
+				logger.Debugf("WebSocket write for session %s failed: %v", w.sessionID, err)
+				return
+			}
+		case <-ticker.C:
+			if !session.IsValid(w.sessionID) {
+				w.writeClose(websocket.CloseNormalClosure, "session expired")
+				return
+			}
+			if err := w.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				logger.Debugf("WebSocket ping for session %s failed: %v", w.sessionID, err)
+				return
+			}
+		case <-w.closing:
+			if w.flush {
+				w.drain()
+			}
+			w.writeClose(w.closeCode, w.closeReason)
+			return
+		}
+	}
+}
+
+func (w *connWriter) write(v any) error {
+	_ = w.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return w.conn.WriteJSON(v)
+}
+
+// drain writes what is already queued, giving up at the first failure.
+func (w *connWriter) drain() {
+	for {
+		select {
+		case v := <-w.queue:
+			if w.write(v) != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (w *connWriter) writeClose(code int, reason string) {
+	msg := websocket.FormatCloseMessage(code, reason)
+	_ = w.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeWait))
+}
+
+// expectPongs makes reads fail once the client stops answering pings.
+func expectPongs(conn *websocket.Conn) {
+	_ = conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+}
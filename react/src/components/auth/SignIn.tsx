@@ -10,6 +10,14 @@ import React, { useState } from "react";
 import { useNavigate, useSearchParams } from "react-router-dom";
 
 import useAuth from "@/hooks/useAuth";
+import { SignInStep } from "@/types/auth";
+
+// A step still waiting for an answer
+type PendingStep = Exclude<SignInStep, { type: "done" }>;
+
+// The server's reason if it gave one, e.g. "invalid code"
+const errorMessage = (err: any) =>
+  err?.response?.data?.error || err?.message || "Something went wrong";
 
 function SignIn() {
   const [username, setUsername] = useState("");
@@ -17,11 +25,29 @@ function SignIn() {
   const [showPassword, setShowPassword] = useState(false);
   const [error, setError] = useState<string | null>(null);
   const [loading, setLoading] = useState(false);
+  // The second factor or PAM prompt being answered, null while entering credentials
+  const [step, setStep] = useState<PendingStep | null>(null);
+  const [answer, setAnswer] = useState("");
 
   const navigate = useNavigate();
   const [searchParams] = useSearchParams();
   const redirect = searchParams.get("redirect") || "/";
-  const { signIn } = useAuth();
+  const { signIn, verifyTwoFactor, respondToPrompt } = useAuth();
+
+  const advance = (next: SignInStep) => {
+    setAnswer("");
+    if (next.type === "done") {
+      navigate(redirect);
+      return;
+    }
+    setStep(next);
+  };
+
+  const restart = () => {
+    setStep(null);
+    setAnswer("");
+    setPassword("");
+  };
 
   const handleSubmit = async (e: React.FormEvent) => {
     e.preventDefault();
@@ -34,15 +60,109 @@ function SignIn() {
 
     try {
       setLoading(true);
-      await signIn(username, password);
-      navigate(redirect);
+      advance(await signIn(username, password));
     } catch (err: any) {
-      setError(err.message || "Something went wrong");
+      setError(errorMessage(err));
     } finally {
       setLoading(false);
     }
   };
 
+  const handleStepSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    if (!step) return;
+    setError(null);
+
+    if (step.type === "two_factor" && !answer) {
+      setError("Enter the code from your authenticator app.");
+      return;
+    }
+
+    try {
+      setLoading(true);
+      advance(
+        step.type === "two_factor"
+          ? await verifyTwoFactor(step.challenge, answer.trim())
+          : await respondToPrompt(step.conversation, answer),
+      );
+    } catch (err: any) {
+      setError(errorMessage(err));
+      // A failed PAM conversation is over; a wrong code can be retried
+      if (step.type === "prompt") restart();
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  if (step) {
+    const hidden = step.type === "prompt" && step.prompt.style === "echo_off";
+    const label =
+      step.type === "prompt"
+        ? step.prompt.text.trim().replace(/:$/, "")
+        : "Authentication code";
+
+    return (
+      <form noValidate onSubmit={handleStepSubmit}>
+        {error && (
+          <Alert severity="warning" sx={{ mb: 3 }}>
+            {error}
+          </Alert>
+        )}
+        {step.type === "prompt" &&
+          step.messages.map((msg, i) => (
+            <Alert
+              key={i}
+              severity={msg.style === "error" ? "error" : "info"}
+              sx={{ mb: 2 }}
+            >
+              {msg.text}
+            </Alert>
+          ))}
+        <TextField
+          label={label}
+          name="answer"
+          type={hidden ? "password" : "text"}
+          fullWidth
+          autoFocus
+          value={answer}
+          onChange={(e) => setAnswer(e.target.value)}
+          sx={{ my: 2 }}
+          autoComplete={
+            step.type === "two_factor"
+              ? "one-time-code"
+              : step.passwordChange
+                ? "new-password"
+                : "off"
+          }
+          helperText={
+            step.type === "two_factor"
+              ? "Enter the code from your authenticator app or a recovery code."
+              : undefined
+          }
+        />
+
+        <Button
+          type="submit"
+          variant="contained"
+          fullWidth
+          color="primary"
+          disabled={loading}
+          sx={{
+            mb: 2,
+            py: 2,
+          }}
+        >
+          {step.type === "prompt" && step.passwordChange
+            ? "Change password"
+            : "Continue"}
+        </Button>
+        <Button fullWidth disabled={loading} onClick={restart} sx={{ mb: 3 }}>
+          Back to sign in
+        </Button>
+      </form>
+    );
+  }
+
   return (
     <form noValidate onSubmit={handleSubmit}>
       {error && (
@@ -6,6 +6,7 @@ import React, {
   useMemo,
 } from "react";
 
+import useAppTheme from "@/hooks/useAppTheme";
 import {
   AuthContextType,
   AuthState,
@@ -13,9 +14,21 @@ import {
   AuthProviderProps,
   AUTH_ACTIONS,
   AuthUser,
+  PAMMessage,
+  SignInStep,
 } from "@/types/auth";
 import axios from "@/utils/axios";
 
+// Body of /auth/login, /auth/login/2fa and /auth/login/respond
+type LoginResponse = {
+  two_factor_required?: boolean;
+  challenge?: string;
+  conversation?: string;
+  prompt?: PAMMessage;
+  messages?: PAMMessage[];
+  password_change?: boolean;
+};
+
 const initialState: AuthState = {
   isAuthenticated: false,
   isInitialized: false,
@@ -57,6 +70,7 @@ AuthContext.displayName = "AuthContext";
 
 function AuthProvider({ children }: AuthProviderProps) {
   const [state, dispatch] = useReducer(reducer, initialState);
+  const { reloadTheme } = useAppTheme();
 
   // Memoize fetchUser so signIn and initialize can depend on it
   const fetchUser = useCallback(async (): Promise<AuthUser> => {
@@ -74,6 +88,11 @@ function AuthProvider({ children }: AuthProviderProps) {
     }
   }, [fetchUser]);
 
+  // The theme is per-session, so load it once a session exists
+  useEffect(() => {
+    if (state.isAuthenticated) reloadTheme();
+  }, [state.isAuthenticated, reloadTheme]);
+
   useEffect(() => {
     initialize();
   }, [initialize]);
@@ -108,18 +127,64 @@ function AuthProvider({ children }: AuthProviderProps) {
     return () => window.removeEventListener("storage", handleStorage);
   }, []);
 
-  // Memoized signIn and signOut
-  const signIn = useCallback(
-    async (username: string, password: string) => {
-      await axios.post("/auth/login", { username, password });
+  // Every login endpoint answers with the next step, or with a session once done
+  const nextStep = useCallback(
+    async (data: LoginResponse): Promise<SignInStep> => {
+      if (data?.two_factor_required && data.challenge) {
+        return { type: "two_factor", challenge: data.challenge };
+      }
+      if (data?.conversation && data.prompt) {
+        return {
+          type: "prompt",
+          conversation: data.conversation,
+          prompt: data.prompt,
+          messages: data.messages ?? [],
+          passwordChange: data.password_change ?? false,
+        };
+      }
       const user = await fetchUser();
       dispatch({ type: AUTH_ACTIONS.SIGN_IN, payload: { user } });
+      return { type: "done" };
     },
     [fetchUser],
   );
 
+  // Memoized sign-in steps and signOut
+  const signIn = useCallback(
+    async (username: string, password: string) => {
+      const { data } = await axios.post<LoginResponse>("/auth/login", {
+        username,
+        password,
+      });
+      return nextStep(data);
+    },
+    [nextStep],
+  );
+
+  const verifyTwoFactor = useCallback(
+    async (challenge: string, code: string) => {
+      const { data } = await axios.post<LoginResponse>("/auth/login/2fa", {
+        challenge,
+        code,
+      });
+      return nextStep(data);
+    },
+    [nextStep],
+  );
+
+  const respondToPrompt = useCallback(
+    async (conversation: string, response: string) => {
+      const { data } = await axios.post<LoginResponse>("/auth/login/respond", {
+        conversation,
+        response,
+      });
+      return nextStep(data);
+    },
+    [nextStep],
+  );
+
   const signOut = useCallback(async () => {
-    await axios.get("/auth/logout");
+    await axios.post("/auth/logout");
     localStorage.setItem("logout", Date.now().toString()); // Broadcast logout
     dispatch({ type: AUTH_ACTIONS.SIGN_OUT });
   }, []);
@@ -130,9 +195,11 @@ function AuthProvider({ children }: AuthProviderProps) {
       ...state,
       method: "session" as const,
       signIn,
+      verifyTwoFactor,
+      respondToPrompt,
       signOut,
     }),
-    [state, signIn, signOut],
+    [state, signIn, verifyTwoFactor, respondToPrompt, signOut],
   );
 
   return (
@@ -23,6 +23,7 @@ const initialState: ThemeContextType = {
   sidebarColapsed: SIDEBAR_COLAPSED_STATE,
   setSidebarColapsed: () => {},
   toggleTheme: () => {},
+  reloadTheme: async () => {},
 };
 
 const ThemeContext = createContext<ThemeContextType>(initialState);
@@ -35,26 +36,29 @@ const ThemeProvider: React.FC<ThemeProviderProps> = ({ children }) => {
   );
   const [isLoaded, setIsLoaded] = useState(false);
 
-  useEffect(() => {
-    const fetchTheme = async () => {
-      try {
-        const response = await axios.get("/theme/get");
-        const fetchedTheme =
-          response.data.theme === "LIGHT" ? THEMES.LIGHT : THEMES.DARK;
-        const fetchedColor = response.data.primaryColor;
-        const fetchedColapsed = response.data.sidebarColapsed;
-        _setTheme(fetchedTheme);
-        _setPrimaryColor(fetchedColor || DEFAULT_PRIMARY_COLOR);
-        _setSidebarColapsed(fetchedColapsed ?? SIDEBAR_COLAPSED_STATE);
-        setIsLoaded(true);
-      } catch (error) {
-        console.error("Error fetching theme from backend:", error);
-      }
-    };
-
-    fetchTheme();
+  // The theme route requires a session, so this is re-run after sign-in
+  const reloadTheme = useCallback(async () => {
+    try {
+      const response = await axios.get("/theme/get");
+      const fetchedTheme =
+        response.data.theme === "LIGHT" ? THEMES.LIGHT : THEMES.DARK;
+      const fetchedColor = response.data.primaryColor;
+      const fetchedColapsed = response.data.sidebarColapsed;
+      _setTheme(fetchedTheme);
+      _setPrimaryColor(fetchedColor || DEFAULT_PRIMARY_COLOR);
+      _setSidebarColapsed(fetchedColapsed ?? SIDEBAR_COLAPSED_STATE);
+    } catch (error) {
+      // Not signed in yet: keep the defaults
+      console.error("Error fetching theme from backend:", error);
+    } finally {
+      setIsLoaded(true);
+    }
   }, []);
 
+  useEffect(() => {
+    reloadTheme();
+  }, [reloadTheme]);
+
   const debouncedSaveThemeSettings = useMemo(() => {
     return debounce(
       (themeToSave: string, colorToSave: string, colapsed: boolean) => {
@@ -117,6 +121,7 @@ const ThemeProvider: React.FC<ThemeProviderProps> = ({ children }) => {
       sidebarColapsed,
       setSidebarColapsed,
       toggleTheme,
+      reloadTheme,
       isLoaded,
     }),
     [
@@ -127,6 +132,7 @@ const ThemeProvider: React.FC<ThemeProviderProps> = ({ children }) => {
       setPrimaryColor,
       setSidebarColapsed,
       toggleTheme,
+      reloadTheme,
       isLoaded,
     ],
   );
@@ -29,6 +29,31 @@ export type AuthState = {
   user: AuthUser | null;
 };
 
+/**
+ * A PAM prompt or message relayed by the server during sign-in.
+ */
+export type PAMMessage = {
+  style: "echo_off" | "echo_on" | "info" | "error";
+  text: string;
+};
+
+/**
+ * What sign-in needs next. Each step but "done" is answered with the
+ * matching `useAuth()` method, which returns the step after it.
+ */
+export type SignInStep =
+  | { type: "done" }
+  /** A TOTP or recovery code, answered with `verifyTwoFactor`. */
+  | { type: "two_factor"; challenge: string }
+  /** A PAM prompt (OTP module, expired password), answered with `respondToPrompt`. */
+  | {
+      type: "prompt";
+      conversation: string;
+      prompt: PAMMessage;
+      messages: PAMMessage[];
+      passwordChange: boolean;
+    };
+
 /**
  * The shape of the public API exposed by `useAuth()` or `AuthContext`.
  */
@@ -37,7 +62,12 @@ export type AuthContextType = {
   isInitialized: boolean;
   user: AuthUser | null;
   method: "session";
-  signIn: (username: string, password: string) => Promise<void>;
+  signIn: (username: string, password: string) => Promise<SignInStep>;
+  verifyTwoFactor: (challenge: string, code: string) => Promise<SignInStep>;
+  respondToPrompt: (
+    conversation: string,
+    response: string,
+  ) => Promise<SignInStep>;
   signOut: () => Promise<void>;
 };
 
@@ -12,6 +12,7 @@ export type ThemeContextType = {
   sidebarColapsed: boolean;
   setSidebarColapsed: (value: boolean | ((prev: boolean) => boolean)) => void;
   toggleTheme: () => void;
+  reloadTheme: () => Promise<void>;
   isLoaded?: boolean;
 };
 
@@ -6,13 +6,36 @@ const axiosInstance = axios.create({
   withCredentials: true,
 });
 
+// State-changing requests must echo the session's CSRF cookie in a header
+const readCookie = (name: string) =>
+  document.cookie
+    .split("; ")
+    .find((c) => c.startsWith(`${name}=`))
+    ?.slice(name.length + 1);
+
+axiosInstance.interceptors.request.use((config) => {
+  const method = (config.method ?? "get").toLowerCase();
+  if (!["get", "head", "options"].includes(method)) {
+    const token = readCookie("csrf_token");
+    if (token) config.headers.set("X-CSRF-Token", token);
+  }
+  return config;
+});
+
 axiosInstance.interceptors.response.use(
   (response) => response,
   (error: AxiosError) => {
     if (error.response) {
       const status = error.response.status;
 
-      if (status === 401 && !error.config?.url?.includes("/auth/me")) {
+      const url = error.config?.url ?? "";
+      // These are also requested before sign-in; login failures are shown by the form
+      if (
+        status === 401 &&
+        !url.includes("/auth/me") &&
+        !url.includes("/auth/login") &&
+        !url.includes("/theme/get")
+      ) {
         const redirectPath = window.location.pathname + window.location.search;
         window.location.href = `/sign-in?redirect=${encodeURIComponent(
           redirectPath,